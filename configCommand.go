@@ -0,0 +1,442 @@
+package xcommon
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// NewConfigCommand creates "config" command with configuration maintenance subcommands.
+// Add it to your root command built by CobraBuilderFunc. configurationResult is the same pointer that is passed to InitCobra
+func NewConfigCommand(configPlan *ConfigurePlan, configurationResult **ConfigurationResult) *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Configuration maintenance commands",
+	}
+	configCmd.AddCommand(newConfigMigrateCommand(configPlan, configurationResult))
+	configCmd.AddCommand(newConfigDiffCommand(configPlan, configurationResult))
+	configCmd.AddCommand(newConfigShowCommand(configPlan, configurationResult))
+	configCmd.AddCommand(newConfigExplainCommand(configurationResult))
+	configCmd.AddCommand(newConfigEncryptCommand(configPlan))
+	configCmd.AddCommand(newConfigDecryptCommand(configPlan))
+	configCmd.AddCommand(newConfigKeygenCommand())
+	return configCmd
+}
+
+func newConfigMigrateCommand(configPlan *ConfigurePlan, configurationResult **ConfigurationResult) *cobra.Command {
+	var dryRun bool
+	migrateCmd := &cobra.Command{
+		Use:   "migrate [file...]",
+		Short: "Migrate config files to the latest config version",
+		Long:  "Migrate config files to the latest config version and show changes. Loaded config files are migrated if no files are specified",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if configPlan.ConfigVersionKey == "" {
+				return fmt.Errorf("config versioning is not enabled")
+			}
+			files := args
+			if len(files) == 0 && *configurationResult != nil {
+				files = (*configurationResult).ParsedConfigs
+			}
+			if len(files) == 0 {
+				return fmt.Errorf("no config files to migrate")
+			}
+			var cfgStruct interface{}
+			if *configurationResult != nil {
+				cfgStruct = (*configurationResult).cfgStruct
+			}
+			secretKeys := collectSecretKeys(configPlan, cfgStruct)
+			for idx, cfgPath := range files {
+				if err := migrateConfigFile(cmd, configPlan, cfgPath, idx > 0, secretKeys, dryRun); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	migrateCmd.Flags().BoolVar(&dryRun, "dry-run", false, "only show changes without rewriting files")
+	return migrateCmd
+}
+
+// migrateConfigFile migrates a single config file, prints a diff and updates changed keys in the file unless dryRun is set.
+// Comments and formatting of YAML, TOML and JSON files are kept, files of other formats are rewritten.
+// Encrypted values are migrated as they are, secret values are redacted in the diff. Encrypted files are not supported.
+// Overlay files without version key are considered to be the latest version (see configMigrationsHook)
+func migrateConfigFile(cmd *cobra.Command, configPlan *ConfigurePlan, cfgPath string, overlay bool, secretKeys map[string]bool, dryRun bool) error {
+	if isTemplateFile(&configPlan.ConfigParsingRules, cfgPath) {
+		return fmt.Errorf("config file '%s' is a template and can't be migrated automatically", cfgPath)
+	}
+	path, format := splitFormatPrefix(cfgPath)
+	data, err := readConfigData(path)
+	if err != nil {
+		return err
+	}
+	if isEncrypted(string(data)) {
+		return fmt.Errorf("config file '%s' is encrypted and can't be migrated automatically, decrypt it first", cfgPath)
+	}
+	if format == "" {
+		format = configFileFormat(path, data)
+	}
+	delimiter := configPlan.ConfigParsingRules.keyDelimiter()
+	settings, err := decodeConfigData(path, format, data, delimiter)
+	if err != nil {
+		return err
+	}
+	migrated := copySettings(settings)
+	unversioned := 0
+	if overlay {
+		unversioned = latestConfigVersion(configPlan)
+	}
+	version, err := migrateConfigSettings(configPlan, migrated, unversioned)
+	if err != nil {
+		return fmt.Errorf("config file '%s': %w", cfgPath, err)
+	}
+	latest := latestConfigVersion(configPlan)
+	if version == latest {
+		fmt.Fprintf(cmd.OutOrStdout(), "%s: already at version %d\n", cfgPath, latest)
+		return nil
+	}
+
+	for key := range encryptedKeys(settings, "", delimiter) {
+		secretKeys[key] = true
+	}
+	before, err := encodeSettings(format, redactSecrets(settings, secretKeys, delimiter), delimiter)
+	if err != nil {
+		return err
+	}
+	after, err := encodeSettings(format, redactSecrets(migrated, secretKeys, delimiter), delimiter)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "%s: version %d -> %d\n", cfgPath, version, latest)
+	fmt.Fprint(cmd.OutOrStdout(), unifiedDiff(cfgPath, cfgPath+" (migrated)", string(before), string(after)))
+	if dryRun {
+		return nil
+	}
+	if path == stdinConfigPath {
+		return fmt.Errorf("standard input can't be rewritten, use --dry-run")
+	}
+	var updated []byte
+	if slices.Contains([]string{"yaml", "yml", "toml", "json", "jsonc", "json5"}, strings.ToLower(format)) {
+		changes := map[string]interface{}{}
+		settingsChanges(settings, migrated, "", changes, delimiter)
+		updated, err = setFileValues(path, format, data, changes, delimiter)
+	} else {
+		updated, err = encodeSettings(format, migrated, delimiter)
+	}
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, updated)
+}
+
+func newConfigDiffCommand(configPlan *ConfigurePlan, configurationResult **ConfigurationResult) *cobra.Command {
+	var jsonOutput bool
+	diffCmd := &cobra.Command{
+		Use:   "diff <left files> <right files>",
+		Short: "Show differences of effective configuration between two sets of config files",
+		Long: "Show differences of effective configuration between two sets of config files. " +
+			"Each set is a comma-separated list of files merged in order (e.g. base.yaml,prod.yaml). Secret values are redacted",
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var defaultConfig map[string]interface{}
+			var cfgStruct interface{}
+			if *configurationResult != nil {
+				defaultConfig = (*configurationResult).defaultConfig
+				cfgStruct = (*configurationResult).cfgStruct
+			}
+			left := planWithFiles(configPlan, strings.Split(args[0], ","))
+			right := planWithFiles(configPlan, strings.Split(args[1], ","))
+			diffs, err := DiffConfigurations(left, right, defaultConfig, cfgStruct)
+			if err != nil {
+				return err
+			}
+			if jsonOutput {
+				encoder := json.NewEncoder(cmd.OutOrStdout())
+				encoder.SetIndent("", "  ")
+				encoder.SetEscapeHTML(false)
+				if diffs == nil {
+					diffs = []ConfigDifference{}
+				}
+				return encoder.Encode(diffs)
+			}
+			for _, diff := range diffs {
+				fmt.Fprintln(cmd.OutOrStdout(), diff.String())
+			}
+			return nil
+		},
+	}
+	diffCmd.Flags().BoolVar(&jsonOutput, "json", false, "print differences as JSON")
+	return diffCmd
+}
+
+// planWithFiles returns a copy of configPlan that loads only concreete config files
+func planWithFiles(configPlan *ConfigurePlan, files []string) *ConfigurePlan {
+	plan := *configPlan
+	plan.ConfigParsingRules.ConcreeteFilePaths = files
+	plan.InlineConfigEnv = ""
+	return &plan
+}
+
+func newConfigShowCommand(configPlan *ConfigurePlan, configurationResult **ConfigurationResult) *cobra.Command {
+	var jsonOutput bool
+	showCmd := &cobra.Command{
+		Use:   "show [command]",
+		Short: "Show effective configuration",
+		Long: "Show effective configuration. If a command is specified (such as \"serve\" or \"db migrate\"), only its config section is shown. " +
+			"Secret values are redacted",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if *configurationResult == nil {
+				return fmt.Errorf("configuration is not loaded")
+			}
+			settings := (*configurationResult).redactedSettings()
+			delimiter := configPlan.ConfigParsingRules.keyDelimiter()
+			if len(args) > 0 {
+				command := strings.Join(args, " ")
+				section, ok := configPlan.CommandSections[command]
+				if !ok {
+					return fmt.Errorf("command '%s' has no config section", command)
+				}
+				subtree, _ := lookupKey(settings, section.Path, delimiter)
+				settings = map[string]interface{}{}
+				setKey(settings, section.Path, subtree, delimiter)
+			}
+			if jsonOutput {
+				encoder := json.NewEncoder(cmd.OutOrStdout())
+				encoder.SetIndent("", "  ")
+				encoder.SetEscapeHTML(false)
+				return encoder.Encode(settings)
+			}
+			data, err := encodeSettings("yaml", settings, delimiter)
+			if err != nil {
+				return err
+			}
+			_, err = cmd.OutOrStdout().Write(data)
+			return err
+		},
+	}
+	showCmd.Flags().BoolVar(&jsonOutput, "json", false, "print configuration as JSON")
+	return showCmd
+}
+
+func newConfigExplainCommand(configurationResult **ConfigurationResult) *cobra.Command {
+	return &cobra.Command{
+		Use:   "explain <key>",
+		Short: "Explain where the effective value of a config key comes from",
+		Long:  "Show the effective value of a config key and every source that sets it from the highest to the lowest precedence. Secret values are redacted",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			result := *configurationResult
+			if result == nil {
+				return fmt.Errorf("configuration is not loaded")
+			}
+			key := strings.ToLower(args[0])
+			delimiter := result.ConfigurePlan.ConfigParsingRules.keyDelimiter()
+			secret := isSecretKey(collectSecretKeys(result.ConfigurePlan, result.cfgStruct), key, delimiter)
+			display := func(value interface{}) interface{} {
+				if secret {
+					return redactedValue
+				}
+				return value
+			}
+			out := cmd.OutOrStdout()
+
+			precedence := sourcePrecedence(result.ConfigurePlan)
+			names := make([]string, len(precedence))
+			for idx, layer := range precedence {
+				names[len(precedence)-1-idx] = string(layer)
+			}
+			fmt.Fprintf(out, "precedence: %s\n", strings.Join(names, " > "))
+			if !result.Viper.IsSet(key) {
+				fmt.Fprintf(out, "%s is not set\n", key)
+				return nil
+			}
+			effective := result.Provenance[key]
+			fmt.Fprintf(out, "%s = %v (%s)\n", key, display(result.Viper.Get(key)), effective)
+			for idx := len(result.layers) - 1; idx >= 0; idx-- {
+				layer := result.layers[idx]
+				value, ok := lookupKey(layer.Settings, key, delimiter)
+				if !ok {
+					continue
+				}
+				marker := " "
+				if layer.Source == effective {
+					marker = "*"
+				}
+				fmt.Fprintf(out, "%s %s: %v\n", marker, layer.Source, display(value))
+			}
+			return nil
+		},
+	}
+}
+
+func newConfigEncryptCommand(configPlan *ConfigurePlan) *cobra.Command {
+	var keys []string
+	var value string
+	var inPlace bool
+	encryptCmd := &cobra.Command{
+		Use:   "encrypt [file]",
+		Short: "Encrypt a config file, its values or a single value",
+		Long: "Encrypt a whole config file, values of --key keys in a YAML, TOML or JSON file, or a single --value. " +
+			"The result is printed unless --in-place is set. The key is taken from the configured key file or environment variable",
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key, err := loadEncryptionKey(&configPlan.ConfigParsingRules)
+			if err != nil {
+				return err
+			}
+			if cmd.Flags().Changed("value") {
+				encrypted, err := encryptValue(key, []byte(value))
+				if err != nil {
+					return err
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), encrypted)
+				return nil
+			}
+			if len(args) == 0 {
+				return fmt.Errorf("config file or --value is required")
+			}
+			cfgPath := args[0]
+			path, _ := splitFormatPrefix(cfgPath)
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			var updated []byte
+			if len(keys) == 0 {
+				if isEncrypted(string(data)) {
+					return fmt.Errorf("config file '%s' is already encrypted", cfgPath)
+				}
+				encrypted, err := encryptValue(key, data)
+				if err != nil {
+					return err
+				}
+				updated = []byte(encrypted + "\n")
+			} else {
+				file, err := readConfigFile(&configPlan.ConfigParsingRules, cfgPath)
+				if err != nil {
+					return err
+				}
+				values := map[string]interface{}{}
+				for _, name := range keys {
+					plain, ok := lookupKey(file.Settings, name, configPlan.ConfigParsingRules.keyDelimiter())
+					if !ok {
+						return fmt.Errorf("config file '%s' has no key '%s'", cfgPath, name)
+					}
+					if _, ok := toStringMap(plain); ok || reflect.ValueOf(plain).Kind() == reflect.Slice {
+						return fmt.Errorf("config file '%s': key '%s' is not a scalar value", cfgPath, name)
+					}
+					if values[name], err = encryptValue(key, []byte(plaintextValue(plain))); err != nil {
+						return err
+					}
+				}
+				if updated, err = setFileValues(path, file.Format, data, values, configPlan.ConfigParsingRules.keyDelimiter()); err != nil {
+					return err
+				}
+			}
+			return writeConfigCommandOutput(cmd, path, updated, inPlace)
+		},
+	}
+	encryptCmd.Flags().StringArrayVar(&keys, "key", nil, "encrypt only the value of this dotted key (YAML, TOML and JSON files only, can be repeated)")
+	encryptCmd.Flags().StringVar(&value, "value", "", "encrypt a single value and print it")
+	encryptCmd.Flags().BoolVar(&inPlace, "in-place", false, "rewrite the file instead of printing the result")
+	return encryptCmd
+}
+
+func newConfigDecryptCommand(configPlan *ConfigurePlan) *cobra.Command {
+	var value string
+	var inPlace bool
+	decryptCmd := &cobra.Command{
+		Use:   "decrypt [file]",
+		Short: "Decrypt a config file, its values or a single value",
+		Long: "Decrypt a whole encrypted config file, encrypted values of a YAML, TOML or JSON file, or a single --value. " +
+			"The result is printed unless --in-place is set",
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key, err := loadEncryptionKey(&configPlan.ConfigParsingRules)
+			if err != nil {
+				return err
+			}
+			if cmd.Flags().Changed("value") {
+				plain, err := decryptValue(key, value)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), string(plain))
+				return nil
+			}
+			if len(args) == 0 {
+				return fmt.Errorf("config file or --value is required")
+			}
+			cfgPath := args[0]
+			path, format := splitFormatPrefix(cfgPath)
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			var updated []byte
+			if isEncrypted(string(data)) {
+				if updated, err = decryptValue(key, string(data)); err != nil {
+					return fmt.Errorf("config file '%s': %w", cfgPath, err)
+				}
+			} else {
+				if format == "" {
+					format = configFileFormat(path, data)
+				}
+				delimiter := configPlan.ConfigParsingRules.keyDelimiter()
+				settings, err := decodeConfigData(path, format, data, delimiter)
+				if err != nil {
+					return err
+				}
+				values := map[string]interface{}{}
+				for name, encrypted := range encryptedKeys(settings, "", delimiter) {
+					plain, err := decryptValue(key, encrypted)
+					if err != nil {
+						return fmt.Errorf("config file '%s', key '%s': %w", cfgPath, name, err)
+					}
+					// Decrypted values are strings the same way as on config load, decode hooks convert them to field types
+					values[name] = string(plain)
+				}
+				if len(values) == 0 {
+					return fmt.Errorf("config file '%s' has no encrypted values", cfgPath)
+				}
+				if updated, err = setFileValues(path, format, data, values, delimiter); err != nil {
+					return err
+				}
+			}
+			return writeConfigCommandOutput(cmd, path, updated, inPlace)
+		},
+	}
+	decryptCmd.Flags().StringVar(&value, "value", "", "decrypt a single value and print it")
+	decryptCmd.Flags().BoolVar(&inPlace, "in-place", false, "rewrite the file instead of printing the result")
+	return decryptCmd
+}
+
+func newConfigKeygenCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "keygen",
+		Short: "Generate a new encryption key for encrypted configs",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key, err := GenerateEncryptionKey()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), key)
+			return nil
+		},
+	}
+}
+
+// writeConfigCommandOutput rewrites cfgPath with data if inPlace is set or prints data otherwise
+func writeConfigCommandOutput(cmd *cobra.Command, cfgPath string, data []byte, inPlace bool) error {
+	if inPlace {
+		return writeFileAtomic(cfgPath, data)
+	}
+	_, err := cmd.OutOrStdout().Write(data)
+	return err
+}
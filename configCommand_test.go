@@ -0,0 +1,60 @@
+package xcommon
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigEncryptDecryptFormatPrefix(t *testing.T) {
+	dir := t.TempDir()
+	key, err := GenerateEncryptionKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("XCOMMON_TEST_KEY", key)
+	cfgPath := filepath.Join(dir, "config")
+	if err := os.WriteFile(cfgPath, []byte("# db\ndb:\n  port: 5432 # port\n  at: 2024-01-02T03:04:05Z\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	plan := &ConfigurePlan{ConfigParsingRules: ViperConfig{EncryptionKeyEnv: "XCOMMON_TEST_KEY"}}
+	var result *ConfigurationResult
+	run := func(args ...string) {
+		t.Helper()
+		cmd := NewConfigCommand(plan, &result)
+		cmd.SetArgs(args)
+		cmd.SetOut(&bytes.Buffer{})
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("config %s: %v", strings.Join(args, " "), err)
+		}
+	}
+
+	run("encrypt", "--in-place", "--key", "db.port", "--key", "db.at", "yaml:"+cfgPath)
+	data, err := os.ReadFile(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(data), encryptedPrefix) != 2 || !strings.Contains(string(data), "# port") {
+		t.Errorf("unexpected encrypted file:\n%s", data)
+	}
+
+	run("decrypt", "--in-place", "yaml:"+cfgPath)
+	if data, err = os.ReadFile(cfgPath); err != nil {
+		t.Fatal(err)
+	}
+	settings, err := decodeConfigData(cfgPath, "yaml", data, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if port, _ := lookupKey(settings, "db.port", "."); port != "5432" {
+		t.Errorf("got port %#v, want \"5432\"", port)
+	}
+	if at, _ := lookupKey(settings, "db.at", "."); at != "2024-01-02T03:04:05Z" {
+		t.Errorf("got at %#v, want \"2024-01-02T03:04:05Z\"", at)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, "*")); len(matches) != 1 {
+		t.Errorf("unexpected files %v", matches)
+	}
+}
@@ -0,0 +1,226 @@
+package xcommon
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/spf13/cast"
+)
+
+// comparisonTags are comparison constraint tags and their checks of a comparison result
+var comparisonTags = map[string]func(cmp int) bool{
+	"gt":  func(cmp int) bool { return cmp > 0 },
+	"gte": func(cmp int) bool { return cmp >= 0 },
+	"lt":  func(cmp int) bool { return cmp < 0 },
+	"lte": func(cmp int) bool { return cmp <= 0 },
+	"ne":  func(cmp int) bool { return cmp != 0 },
+}
+
+// comparisonNames are human readable names of comparison tags
+var comparisonNames = map[string]string{
+	"gt":  "greater than",
+	"gte": "greater than or equal to",
+	"lt":  "less than",
+	"lte": "less than or equal to",
+	"ne":  "not equal to",
+}
+
+// validateConfigStruct checks declarative constraints and calls Validate methods of cfgStruct and its nested structures.
+// All violations are aggregated. Constraint tags refer to other fields by dotted config keys relative to the structure
+// containing the field (such as "max_conns" for "db.min_conns"), absolute keys are used if there is no such relative key.
+// Conditions are comma-separated (all must hold), each condition is "key" (the key is set to a non-zero value), "key=value" or "key!=value"
+//
+//	required_if:"tls.enabled=true"   - field must be set if conditions hold
+//	excluded_with:"mode=cluster"     - field must not be set if conditions hold
+//	gt, gte, lt, lte, ne:"max_conns" - comparison with another key or a literal of the field type (a number, "1h", "10MiB").
+//	                                   ne also compares non-numeric values (strings, bools) for equality
+func validateConfigStruct(cfgStruct interface{}, delimiter string) error {
+	fields := map[string]reflect.Value{}
+	var order []configStructField
+	walkConfigStruct(cfgStruct, delimiter, func(field configStructField) bool {
+		value, ok := fieldByIndex(reflect.ValueOf(field.Root), field.Index)
+		if !ok {
+			return false
+		}
+		fields[field.Key] = value
+		order = append(order, field)
+		return true
+	})
+
+	var errs []error
+	for _, field := range order {
+		errs = append(errs, checkFieldConstraints(field, fields, delimiter)...)
+	}
+	errs = append(errs, walkConfigTreeValues(cfgStruct, delimiter, func(value reflect.Value, key string) error {
+		if validator, ok := configInterface[ConfigValidator](value); ok {
+			return keyError(key, validator.Validate())
+		}
+		return nil
+	})...)
+	return errors.Join(errs...)
+}
+
+// checkFieldConstraints checks constraint tags of a single field
+func checkFieldConstraints(field configStructField, fields map[string]reflect.Value, delimiter string) []error {
+	var errs []error
+	value := fields[field.Key]
+	if tag, ok := field.Field.Tag.Lookup("required_if"); ok {
+		holds, err := constraintConditionsHold(tag, field.Key, fields, delimiter)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("config key '%s': invalid required_if: %w", field.Key, err))
+		} else if holds && value.IsZero() {
+			errs = append(errs, fmt.Errorf("config key '%s' is required when %s", field.Key, describeConditions(tag)))
+		}
+	}
+	if tag, ok := field.Field.Tag.Lookup("excluded_with"); ok {
+		holds, err := constraintConditionsHold(tag, field.Key, fields, delimiter)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("config key '%s': invalid excluded_with: %w", field.Key, err))
+		} else if holds && !value.IsZero() {
+			errs = append(errs, fmt.Errorf("config key '%s' must not be set when %s", field.Key, describeConditions(tag)))
+		}
+	}
+	for _, name := range []string{"gt", "gte", "lt", "lte", "ne"} {
+		operand, ok := field.Field.Tag.Lookup(name)
+		if !ok {
+			continue
+		}
+		other, otherDesc := parseConstraintLiteral(value.Type(), operand), operand
+		if otherKey, otherValue, ok := constraintField(fields, field.Key, operand, delimiter); ok {
+			other = otherValue.Interface()
+			otherDesc = fmt.Sprintf("'%s' (%v)", otherKey, other)
+		}
+		cmp, err := compareConfigValues(value.Interface(), other)
+		if err != nil && name == "ne" {
+			cmp, err = 1, nil
+			if constraintString(value.Interface()) == constraintString(other) {
+				cmp = 0
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("config key '%s': invalid %s constraint: %w", field.Key, name, err))
+		} else if !comparisonTags[name](cmp) {
+			errs = append(errs, fmt.Errorf("config key '%s' (%v) must be %s %s", field.Key, value.Interface(), comparisonNames[name], otherDesc))
+		}
+	}
+	return errs
+}
+
+// constraintField finds a field referenced by a constraint of the field at key.
+// The reference is relative to the structure containing the field or absolute
+func constraintField(fields map[string]reflect.Value, key string, ref string, delimiter string) (string, reflect.Value, bool) {
+	ref = strings.ToLower(strings.TrimSpace(ref))
+	if parent, ok := parentKey(key, delimiter); ok {
+		if value, ok := fields[joinKey(parent, ref, delimiter)]; ok {
+			return joinKey(parent, ref, delimiter), value, true
+		}
+	}
+	value, ok := fields[ref]
+	return ref, value, ok
+}
+
+// constraintString converts a value to a string to compare with constraint literals
+func constraintString(value interface{}) string {
+	ret, err := cast.ToStringE(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return ret
+}
+
+// constraintConditionsHold checks if all comma-separated conditions of the field at fieldKey hold
+func constraintConditionsHold(conditions string, fieldKey string, fields map[string]reflect.Value, delimiter string) (bool, error) {
+	for _, condition := range strings.Split(conditions, ",") {
+		condition = strings.TrimSpace(condition)
+		key, expected, negate := condition, "", false
+		hasValue := false
+		if k, v, ok := strings.Cut(condition, "!="); ok {
+			key, expected, negate, hasValue = k, v, true, true
+		} else if k, v, ok := strings.Cut(condition, "="); ok {
+			key, expected, hasValue = k, v, true
+		}
+		key, value, ok := constraintField(fields, fieldKey, key, delimiter)
+		if !ok {
+			return false, fmt.Errorf("unknown config key '%s'", key)
+		}
+		if !hasValue {
+			if value.IsZero() {
+				return false, nil
+			}
+			continue
+		}
+		if (constraintString(value.Interface()) == strings.TrimSpace(expected)) == negate {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// describeConditions makes conditions human readable
+func describeConditions(conditions string) string {
+	parts := strings.Split(conditions, ",")
+	for idx, part := range parts {
+		part = strings.TrimSpace(part)
+		if !strings.Contains(part, "=") {
+			part += " is set"
+		}
+		parts[idx] = "'" + part + "'"
+	}
+	return strings.Join(parts, " and ")
+}
+
+// compareConfigValues compares two numeric values (durations and sizes are numbers too)
+func compareConfigValues(left interface{}, right interface{}) (int, error) {
+	l, err := configNumber(left)
+	if err != nil {
+		return 0, err
+	}
+	r, err := configNumber(right)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case l < r:
+		return -1, nil
+	case l > r:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// parseConstraintLiteral parses a literal operand as a value of the field type if the type has a text form (such as "1h" or "10MiB")
+func parseConstraintLiteral(fieldType reflect.Type, literal string) interface{} {
+	if fieldType == reflect.TypeOf(time.Duration(0)) {
+		if duration, err := time.ParseDuration(literal); err == nil {
+			return duration
+		}
+		return literal
+	}
+	ptr := reflect.New(fieldType)
+	if unmarshaler, ok := ptr.Interface().(encoding.TextUnmarshaler); ok && unmarshaler.UnmarshalText([]byte(literal)) == nil {
+		return ptr.Elem().Interface()
+	}
+	return literal
+}
+
+// configNumber converts a value of any numeric kind (including named types like Duration and ByteSize) or a numeric string to float64
+func configNumber(value interface{}) (float64, error) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	}
+	ret, err := cast.ToFloat64E(value)
+	if err != nil {
+		return 0, fmt.Errorf("'%v' is not a number", value)
+	}
+	return ret, nil
+}
@@ -0,0 +1,58 @@
+package xcommon
+
+import (
+	"testing"
+)
+
+type constraintsTestDB struct {
+	MinConns int    `mapstructure:"min_conns" lte:"max_conns"`
+	MaxConns int    `mapstructure:"max_conns" lte:"limit"`
+	Mode     string `ne:"mode_fallback"`
+	Replica  bool   `ne:"true"`
+	Host     string `required_if:"replica"`
+}
+
+type constraintsTestConfig struct {
+	Limit        int
+	ModeFallback string `mapstructure:"mode_fallback"`
+	DB           constraintsTestDB
+}
+
+func TestValidateConfigStructConstraints(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   constraintsTestConfig
+		valid bool
+	}{
+		{"valid", constraintsTestConfig{Limit: 10, ModeFallback: "b", DB: constraintsTestDB{MinConns: 1, MaxConns: 5, Mode: "a"}}, true},
+		{"relative key", constraintsTestConfig{Limit: 10, DB: constraintsTestDB{MinConns: 6, MaxConns: 5, Mode: "a"}}, false},
+		{"absolute key fallback", constraintsTestConfig{Limit: 4, DB: constraintsTestDB{MinConns: 1, MaxConns: 5, Mode: "a"}}, false},
+		{"ne on strings", constraintsTestConfig{Limit: 10, ModeFallback: "a", DB: constraintsTestDB{Mode: "a"}}, false},
+		{"ne on bools", constraintsTestConfig{Limit: 10, DB: constraintsTestDB{Mode: "a", Replica: true, Host: "h"}}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateConfigStruct(&test.cfg, ".")
+			if test.valid && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !test.valid && err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
+
+func TestValidateConfigStructRequiredIfRelative(t *testing.T) {
+	type replicaConfig struct {
+		DB struct {
+			Replica int
+			Host    string `required_if:"replica=2"`
+		}
+	}
+	replica := replicaConfig{}
+	replica.DB.Replica = 2
+	if err := validateConfigStruct(&replica, "."); err == nil {
+		t.Errorf("expected error")
+	}
+}
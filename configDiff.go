@@ -0,0 +1,159 @@
+package xcommon
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cast"
+)
+
+// ConfigChange is a kind of difference between two configurations
+type ConfigChange string
+
+const (
+	ConfigAdded   ConfigChange = "added"   // Key exists only in the right configuration
+	ConfigRemoved ConfigChange = "removed" // Key exists only in the left configuration
+	ConfigChanged ConfigChange = "changed" // Key has different values
+)
+
+// ConfigDifference is a difference of a single effective config value
+type ConfigDifference struct {
+	Key    string       `json:"key"`             // Dotted config key
+	Change ConfigChange `json:"change"`          // Kind of the difference
+	Left   interface{}  `json:"left,omitempty"`  // Value in the left configuration. Secrets are redacted
+	Right  interface{}  `json:"right,omitempty"` // Value in the right configuration. Secrets are redacted
+}
+
+func (diff ConfigDifference) String() string {
+	switch diff.Change {
+	case ConfigAdded:
+		return fmt.Sprintf("+ %s: %v", diff.Key, diff.Right)
+	case ConfigRemoved:
+		return fmt.Sprintf("- %s: %v", diff.Key, diff.Left)
+	default:
+		return fmt.Sprintf("~ %s: %v -> %v", diff.Key, diff.Left, diff.Right)
+	}
+}
+
+// DiffConfigurations loads config files of two configuration plans, applies defaultConfig
+// and returns differences of effective values sorted by key. Environment and flags are not used.
+// Values of secret keys (see ConfigurePlan.SecretKeys) are redacted. Both plans must use the same key delimiter
+func DiffConfigurations(
+	left *ConfigurePlan,
+	right *ConfigurePlan,
+	defaultConfig map[string]interface{},
+	cfgStruct interface{},
+) ([]ConfigDifference, error) {
+	delimiter := left.ConfigParsingRules.keyDelimiter()
+	if rightDelimiter := right.ConfigParsingRules.keyDelimiter(); rightDelimiter != delimiter {
+		return nil, fmt.Errorf("configurations use different key delimiters '%s' and '%s'", delimiter, rightDelimiter)
+	}
+	leftSettings, err := loadEffectiveSettings(left, defaultConfig, cfgStruct)
+	if err != nil {
+		return nil, err
+	}
+	rightSettings, err := loadEffectiveSettings(right, defaultConfig, cfgStruct)
+	if err != nil {
+		return nil, err
+	}
+	secretKeys := collectSecretKeys(left, cfgStruct)
+	for key := range collectSecretKeys(right, cfgStruct) {
+		secretKeys[key] = true
+	}
+	return diffSettings(leftSettings, rightSettings, secretKeys, delimiter), nil
+}
+
+// loadEffectiveSettings loads config files of configPlan with defaults into a flat map of dotted keys
+func loadEffectiveSettings(configPlan *ConfigurePlan, defaultConfig map[string]interface{}, cfgStruct interface{}) (map[string]interface{}, error) {
+	vp, _, err := loadConfigFiles(newViper(configPlan.ConfigParsingRules.keyDelimiter()), configPlan, cfgStruct)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range defaultConfig {
+		vp.SetDefault(key, value)
+	}
+	settings := map[string]interface{}{}
+	for _, key := range vp.AllKeys() {
+		settings[key] = vp.Get(key)
+	}
+	return settings, nil
+}
+
+// diffSettings compares two flat settings maps
+func diffSettings(left map[string]interface{}, right map[string]interface{}, secretKeys map[string]bool, delimiter string) []ConfigDifference {
+	keys := map[string]bool{}
+	for key := range left {
+		keys[key] = true
+	}
+	for key := range right {
+		keys[key] = true
+	}
+	sortedKeys := make([]string, 0, len(keys))
+	for key := range keys {
+		sortedKeys = append(sortedKeys, key)
+	}
+	sort.Strings(sortedKeys)
+
+	var ret []ConfigDifference
+	for _, key := range sortedKeys {
+		leftValue, inLeft := left[key]
+		rightValue, inRight := right[key]
+		diff := ConfigDifference{Key: key, Left: leftValue, Right: rightValue}
+		switch {
+		case !inLeft:
+			diff.Change = ConfigAdded
+		case !inRight:
+			diff.Change = ConfigRemoved
+		case !valuesEqual(leftValue, rightValue):
+			diff.Change = ConfigChanged
+		default:
+			continue
+		}
+		if isSecretKey(secretKeys, key, delimiter) {
+			if inLeft {
+				diff.Left = redactedValue
+			}
+			if inRight {
+				diff.Right = redactedValue
+			}
+		}
+		ret = append(ret, diff)
+	}
+	return ret
+}
+
+// valuesEqual compares config values ignoring differences of numeric types and string representations of scalars
+func valuesEqual(a interface{}, b interface{}) bool {
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+	aList, aIsList := a.([]interface{})
+	bList, bIsList := b.([]interface{})
+	if aIsList && bIsList {
+		if len(aList) != len(bList) {
+			return false
+		}
+		for idx := range aList {
+			if !valuesEqual(aList[idx], bList[idx]) {
+				return false
+			}
+		}
+		return true
+	}
+	if aIsList || bIsList || isMapValue(a) || isMapValue(b) {
+		return false
+	}
+	aFloat, aErr := cast.ToFloat64E(a)
+	bFloat, bErr := cast.ToFloat64E(b)
+	if aErr == nil && bErr == nil {
+		return aFloat == bFloat
+	}
+	return strings.EqualFold(fmt.Sprint(a), fmt.Sprint(b)) && reflect.TypeOf(a) != reflect.TypeOf(b)
+}
+
+func isMapValue(value interface{}) bool {
+	_, ok := toStringMap(value)
+	return ok
+}
@@ -0,0 +1,208 @@
+package xcommon
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	encryptedPrefix    = "ENC[aes-gcm:" // Prefix of an encrypted value or file
+	encryptedSuffix    = "]"            // Suffix of an encrypted value or file
+	encryptionKeyBytes = 32             // Size of generated keys (AES-256)
+)
+
+// GenerateEncryptionKey returns a new random base64-encoded AES-256 key for encrypted config files and values
+func GenerateEncryptionKey() (string, error) {
+	key := make([]byte, encryptionKeyBytes)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// loadEncryptionKey loads base64-encoded AES key from ViperConfig.EncryptionKeyEnv or ViperConfig.EncryptionKeyFile.
+// Environment variable has precedence over the key file
+func loadEncryptionKey(viperConfig *ViperConfig) ([]byte, error) {
+	var encoded, source string
+	if viperConfig.EncryptionKeyEnv != "" {
+		encoded = strings.TrimSpace(os.Getenv(viperConfig.EncryptionKeyEnv))
+		source = "environment variable " + viperConfig.EncryptionKeyEnv
+	}
+	if encoded == "" && viperConfig.EncryptionKeyFile != "" {
+		keyPath := expandPath(viperConfig.EncryptionKeyFile)
+		if err := checkSecretFile(viperConfig.SecretFilePermissions, keyPath, "encryption key file"); err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read encryption key: %w", err)
+		}
+		encoded = strings.TrimSpace(string(data))
+		source = "key file " + keyPath
+	}
+	if encoded == "" {
+		return nil, fmt.Errorf("encryption key is not configured")
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key in %s: %w", source, err)
+	}
+	if len(key) != 16 && len(key) != 24 && len(key) != 32 {
+		return nil, fmt.Errorf("invalid encryption key in %s: expected 16, 24 or 32 bytes, got %d", source, len(key))
+	}
+	return key, nil
+}
+
+// isEncrypted checks if value is an encrypted envelope "ENC[aes-gcm:...]"
+func isEncrypted(value string) bool {
+	value = strings.TrimSpace(value)
+	return strings.HasPrefix(value, encryptedPrefix) && strings.HasSuffix(value, encryptedSuffix) && !strings.ContainsAny(value, "\r\n")
+}
+
+// encryptValue encrypts plaintext into "ENC[aes-gcm:base64(nonce+ciphertext)]"
+func encryptValue(key []byte, plaintext []byte) (string, error) {
+	aead, err := newConfigAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed) + encryptedSuffix, nil
+}
+
+// decryptValue decrypts an envelope produced by encryptValue
+func decryptValue(key []byte, value string) ([]byte, error) {
+	value = strings.TrimSpace(value)
+	if !isEncrypted(value) {
+		return nil, fmt.Errorf("value is not encrypted")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimSuffix(strings.TrimPrefix(value, encryptedPrefix), encryptedSuffix))
+	if err != nil {
+		return nil, fmt.Errorf("invalid encrypted value: %w", err)
+	}
+	aead, err := newConfigAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("invalid encrypted value: too short")
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt value: wrong key or corrupted data")
+	}
+	return plaintext, nil
+}
+
+func newConfigAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// decryptConfigData decrypts a whole encrypted config file. Plain data is returned as is
+func decryptConfigData(viperConfig *ViperConfig, cfgPath string, data []byte) ([]byte, error) {
+	if !isEncrypted(string(bytes.TrimSpace(data))) {
+		return data, nil
+	}
+	key, err := loadEncryptionKey(viperConfig)
+	if err != nil {
+		return nil, fmt.Errorf("config file '%s' is encrypted: %w", cfgPath, err)
+	}
+	plaintext, err := decryptValue(key, string(data))
+	if err != nil {
+		return nil, fmt.Errorf("config file '%s': %w", cfgPath, err)
+	}
+	return plaintext, nil
+}
+
+// decryptSettings replaces encrypted string values in settings (including lists) with their plaintext.
+// The key is loaded only if there are encrypted values
+func decryptSettings(viperConfig *ViperConfig, cfgPath string, settings map[string]interface{}) error {
+	var key []byte
+	var decrypt func(name string, value interface{}) (interface{}, error)
+	decrypt = func(name string, value interface{}) (interface{}, error) {
+		if m, ok := toStringMap(value); ok {
+			for k, v := range m {
+				decrypted, err := decrypt(joinKey(name, k, viperConfig.keyDelimiter()), v)
+				if err != nil {
+					return nil, err
+				}
+				m[k] = decrypted
+			}
+			return m, nil
+		}
+		switch v := value.(type) {
+		case []interface{}:
+			for idx, item := range v {
+				decrypted, err := decrypt(fmt.Sprintf("%s[%d]", name, idx), item)
+				if err != nil {
+					return nil, err
+				}
+				v[idx] = decrypted
+			}
+			return v, nil
+		case string:
+			if !isEncrypted(v) {
+				return v, nil
+			}
+			if key == nil {
+				var err error
+				if key, err = loadEncryptionKey(viperConfig); err != nil {
+					return nil, fmt.Errorf("config file '%s' has encrypted value '%s': %w", cfgPath, name, err)
+				}
+			}
+			plaintext, err := decryptValue(key, v)
+			if err != nil {
+				return nil, fmt.Errorf("config file '%s', key '%s': %w", cfgPath, name, err)
+			}
+			return string(plaintext), nil
+		}
+		return value, nil
+	}
+	for k, v := range settings {
+		decrypted, err := decrypt(k, v)
+		if err != nil {
+			return err
+		}
+		settings[k] = decrypted
+	}
+	return nil
+}
+
+// plaintextValue converts a scalar config value to plaintext for encryption.
+// Decrypted values are strings, so the text form must be accepted by decode hooks of the field type
+func plaintextValue(value interface{}) string {
+	if t, ok := value.(time.Time); ok {
+		return t.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(value)
+}
+
+// encryptedKeys returns dotted keys of encrypted string values in settings (lists are not traversed)
+func encryptedKeys(settings map[string]interface{}, prefix string, delimiter string) map[string]string {
+	ret := map[string]string{}
+	for k, v := range settings {
+		key := joinKey(prefix, k, delimiter)
+		if m, ok := toStringMap(v); ok {
+			for nestedKey, nestedValue := range encryptedKeys(m, key, delimiter) {
+				ret[nestedKey] = nestedValue
+			}
+		} else if str, ok := v.(string); ok && isEncrypted(str) {
+			ret[key] = str
+		}
+	}
+	return ret
+}
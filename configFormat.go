@@ -0,0 +1,104 @@
+package xcommon
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const stdinConfigPath = "-" // Config path meaning standard input
+
+var yamlKeyLineRe = regexp.MustCompile(`^\s*(- |---|[\w.-]+\s*:(\s|$))`)
+
+// configFormats returns all supported config formats
+func configFormats() []string {
+	return append([]string{"jsonc", "json5"}, viper.SupportedExts...)
+}
+
+// splitFormatPrefix splits an explicit format override like "yaml:/etc/app/config" into path and format.
+// Format is empty if there is no override
+func splitFormatPrefix(cfgPath string) (string, string) {
+	if prefix, path, ok := strings.Cut(cfgPath, ":"); ok && slices.Contains(configFormats(), strings.ToLower(prefix)) {
+		return path, strings.ToLower(prefix)
+	}
+	return cfgPath, ""
+}
+
+// readConfigData reads config file contents. "-" means standard input
+func readConfigData(cfgPath string) ([]byte, error) {
+	if cfgPath == stdinConfigPath {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(cfgPath)
+}
+
+// configFileFormat returns format of a config file by its extension (".tmpl" suffix is ignored) or by its contents
+func configFileFormat(cfgPath string, data []byte) string {
+	if ext := filepath.Ext(strings.TrimSuffix(cfgPath, templateExt)); ext != "" && cfgPath != stdinConfigPath {
+		return strings.TrimLeft(ext, ".")
+	}
+	return detectConfigFormat(data)
+}
+
+// detectConfigFormat guesses config format by contents. JSON, YAML, TOML, INI and HCL are recognized
+func detectConfigFormat(data []byte) string {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")) // UTF-8 BOM
+	var significant []string
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, ";") || strings.HasPrefix(trimmed, "//") {
+			continue
+		}
+		significant = append(significant, line)
+	}
+	if len(significant) == 0 {
+		return "yaml"
+	}
+	if strings.HasPrefix(strings.TrimSpace(significant[0]), "{") {
+		if canDecodeConfig("json", data) {
+			return "json"
+		}
+		return "json5"
+	}
+	for _, line := range significant {
+		if yamlKeyLineRe.MatchString(line) {
+			return "yaml"
+		}
+	}
+	for _, format := range []string{"toml", "hcl", "ini"} {
+		if canDecodeConfig(format, data) {
+			return format
+		}
+	}
+	return "yaml"
+}
+
+func canDecodeConfig(format string, data []byte) bool {
+	decoder := newViper(".") // Key delimiter doesn't matter for syntax checks
+	decoder.SetConfigType(format)
+	return decoder.ReadConfig(bytes.NewReader(data)) == nil
+}
+
+// splitConfigPaths splits a list of config paths separated by os.PathListSeparator.
+// "format:path" items are kept together when the separator is ':'
+func splitConfigPaths(value string) []string {
+	var ret []string
+	parts := strings.Split(value, string(os.PathListSeparator))
+	for idx := 0; idx < len(parts); idx++ {
+		part := strings.TrimSpace(parts[idx])
+		if os.PathListSeparator == ':' && slices.Contains(configFormats(), strings.ToLower(part)) && idx+1 < len(parts) {
+			idx++
+			part += ":" + strings.TrimSpace(parts[idx])
+		}
+		if part != "" {
+			ret = append(ret, part)
+		}
+	}
+	return ret
+}
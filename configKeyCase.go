@@ -0,0 +1,131 @@
+package xcommon
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// decodeOriginalKeys parses config data without lowercasing keys (viper always lowercases them).
+// Only YAML, JSON and TOML are supported, nil is returned for other formats
+func decodeOriginalKeys(format string, data []byte) map[string]interface{} {
+	settings := map[string]interface{}{}
+	var err error
+	switch format {
+	case "yaml", "yml":
+		err = yaml.Unmarshal(data, &settings)
+	case "json", "jsonc", "json5":
+		if format != "json" {
+			if data, _, err = convertToJSON(data, format == "json5"); err != nil {
+				return nil
+			}
+		}
+		err = json.Unmarshal(data, &settings)
+	case "toml":
+		err = toml.Unmarshal(data, &settings)
+	default:
+		return nil
+	}
+	if err != nil {
+		return nil // Viper has already parsed the data, so it's not a parse error to report
+	}
+	return settings
+}
+
+// mergeOriginalKeys merges keys of src into dst. Keys of later sources win when they differ in case only
+func mergeOriginalKeys(dst map[string]interface{}, src map[string]interface{}) {
+	for srcKey, srcValue := range src {
+		dstKey := findMapKey(dst, strings.ToLower(srcKey))
+		dstMap, dstIsMap := toStringMap(dst[dstKey])
+		srcMap, srcIsMap := toStringMap(srcValue)
+		delete(dst, dstKey)
+		if dstIsMap && srcIsMap {
+			merged := copySettings(dstMap)
+			mergeOriginalKeys(merged, srcMap)
+			dst[srcKey] = merged
+			continue
+		}
+		dst[srcKey] = srcValue
+	}
+}
+
+// restoreKeyCase renames map keys of cfgStruct and config sections to their original case from config files
+func restoreKeyCase(result *ConfigurationResult, cfgStruct interface{}) {
+	if !result.ConfigurePlan.ConfigParsingRules.PreserveKeyCase {
+		return
+	}
+	original := map[string]interface{}{}
+	for _, file := range result.configFiles {
+		mergeOriginalKeys(original, file.Original)
+	}
+	delimiter := result.ConfigurePlan.ConfigParsingRules.keyDelimiter()
+	for _, root := range configStructRoots(cfgStruct) {
+		var subtree interface{} = original
+		if path := joinKey(result.ConfigurePlan.ConfigParsingRules.ExtractSubtree, root.Prefix, delimiter); path != "" {
+			subtree, _ = lookupKey(original, path, delimiter)
+		}
+		restoreValueKeyCase(reflect.ValueOf(root.Struct), subtree)
+	}
+}
+
+// restoreValueKeyCase renames lowercased string keys of maps in value (including nested structures, maps and lists)
+// to keys of the original settings that differ in case only
+func restoreValueKeyCase(value reflect.Value, original interface{}) {
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return
+		}
+		value = value.Elem()
+	}
+	switch value.Kind() {
+	case reflect.Struct:
+		settings, ok := toStringMap(original)
+		if !ok {
+			return
+		}
+		t := value.Type()
+		for idx := 0; idx < t.NumField(); idx++ {
+			f := t.Field(idx)
+			if !f.IsExported() {
+				continue
+			}
+			name, squash := configFieldName(f)
+			if name == "-" {
+				continue
+			}
+			if squash {
+				restoreValueKeyCase(value.Field(idx), settings)
+			} else if fieldOriginal, ok := settings[findMapKey(settings, name)]; ok {
+				restoreValueKeyCase(value.Field(idx), fieldOriginal)
+			}
+		}
+	case reflect.Map:
+		settings, ok := toStringMap(original)
+		if !ok || value.IsNil() || value.Type().Key().Kind() != reflect.String {
+			return
+		}
+		for _, mapKey := range value.MapKeys() {
+			name := findMapKey(settings, strings.ToLower(mapKey.String()))
+			itemOriginal, ok := settings[name]
+			if !ok {
+				continue // The key is not from config files
+			}
+			item := reflect.New(value.Type().Elem()).Elem()
+			item.Set(value.MapIndex(mapKey))
+			restoreValueKeyCase(item, itemOriginal)
+			value.SetMapIndex(mapKey, reflect.Value{})
+			value.SetMapIndex(reflect.ValueOf(name).Convert(value.Type().Key()), item)
+		}
+	case reflect.Slice, reflect.Array:
+		list, ok := original.([]interface{})
+		if !ok {
+			return
+		}
+		for idx := 0; idx < min(value.Len(), len(list)); idx++ {
+			restoreValueKeyCase(value.Index(idx), list[idx])
+		}
+	}
+}
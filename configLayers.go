@@ -0,0 +1,353 @@
+package xcommon
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// ConfigLayer is a layer of configuration sources in ConfigurePlan.SourcePrecedence.
+// Names of ConfigurePlan.CustomSources are layers too
+type ConfigLayer string
+
+const (
+	LayerDefaults ConfigLayer = "defaults" // Default config and defaults of bound flags
+	LayerFiles    ConfigLayer = "files"    // Config files and inline config
+	LayerDotenv   ConfigLayer = "dotenv"   // Variables of ConfigurePlan.DotenvFile
+	LayerEnv      ConfigLayer = "env"      // Environment variables
+	LayerFlags    ConfigLayer = "flags"    // Changed command line flags
+	LayerSet      ConfigLayer = "set"      // --set and --set-file overrides
+)
+
+// ConfigSource is a custom source of config values (such as a management system). Keys may be nested maps or dotted keys
+type ConfigSource func() (map[string]interface{}, error)
+
+// DefaultSourcePrecedence returns the viper order of layers from the lowest to the highest precedence
+func DefaultSourcePrecedence() []ConfigLayer {
+	return []ConfigLayer{LayerDefaults, LayerFiles, LayerEnv, LayerFlags, LayerSet}
+}
+
+// sourceLayer is settings of a single source within a layer
+type sourceLayer struct {
+	Layer    ConfigLayer            // Layer of the source
+	Source   ValueSource            // The source itself
+	Settings map[string]interface{} // Settings of the source relative to ExtractSubtree
+	Replace  bool                   // Settings replace previous values regardless of merge strategies
+}
+
+// sourcePrecedence returns configured layers from the lowest to the highest precedence
+func sourcePrecedence(configPlan *ConfigurePlan) []ConfigLayer {
+	if len(configPlan.SourcePrecedence) == 0 {
+		return DefaultSourcePrecedence()
+	}
+	return configPlan.SourcePrecedence
+}
+
+// checkSourcePrecedence rejects unknown, duplicate and unlisted custom layers
+func checkSourcePrecedence(configPlan *ConfigurePlan) error {
+	if len(configPlan.SourcePrecedence) == 0 {
+		if len(configPlan.CustomSources) > 0 || configPlan.DotenvFile != "" {
+			return fmt.Errorf("custom sources and dotenv file require SourcePrecedence")
+		}
+		return nil
+	}
+	builtin := []ConfigLayer{LayerDefaults, LayerFiles, LayerDotenv, LayerEnv, LayerFlags, LayerSet}
+	for idx, layer := range configPlan.SourcePrecedence {
+		if slices.Contains(configPlan.SourcePrecedence[:idx], layer) {
+			return fmt.Errorf("config source layer '%s' is listed twice", layer)
+		}
+		if _, ok := configPlan.CustomSources[string(layer)]; !ok && !slices.Contains(builtin, layer) {
+			return fmt.Errorf("unknown config source layer '%s'", layer)
+		}
+	}
+	for name := range configPlan.CustomSources {
+		if !slices.Contains(configPlan.SourcePrecedence, ConfigLayer(name)) {
+			return fmt.Errorf("custom config source '%s' is not listed in SourcePrecedence", name)
+		}
+	}
+	return nil
+}
+
+// collectSourceLayers returns settings of every source in order of precedence (from the lowest to the highest).
+// overrides are values of --set and --set-file flags by base keys
+func collectSourceLayers(
+	result *ConfigurationResult,
+	bindFlags map[string]*pflag.Flag,
+	overrides map[string]interface{},
+) ([]sourceLayer, error) {
+	configPlan := result.ConfigurePlan
+	delimiter := configPlan.ConfigParsingRules.keyDelimiter()
+	knownKeys := knownConfigKeys(result, bindFlags)
+	var layers []sourceLayer
+	for _, layer := range sourcePrecedence(configPlan) {
+		switch layer {
+		case LayerDefaults:
+			defaults := map[string]interface{}{}
+			if !configPlan.DontBindFlagsToConfig {
+				for key, flag := range bindFlags {
+					setKey(defaults, key, flagValue(flag, true), delimiter)
+				}
+			}
+			for key, value := range result.defaultConfig {
+				setKey(defaults, key, value, delimiter)
+			}
+			layers = append(layers, sourceLayer{Layer: layer, Source: ValueSource{Kind: SourceDefault}, Settings: defaults})
+		case LayerFiles:
+			for _, file := range result.configFiles {
+				settings := file.Settings
+				if subtree := configPlan.ConfigParsingRules.ExtractSubtree; subtree != "" {
+					value, _ := lookupKey(file.Settings, subtree, delimiter)
+					if settings, _ = toStringMap(value); settings == nil {
+						continue
+					}
+				}
+				layers = append(layers, sourceLayer{Layer: layer, Source: ValueSource{Kind: file.Kind, Name: file.Path}, Settings: settings})
+			}
+		case LayerDotenv:
+			if configPlan.DontBindEnvToConfig || configPlan.DotenvFile == "" {
+				continue
+			}
+			data, err := os.ReadFile(expandPath(configPlan.DotenvFile))
+			if err != nil {
+				return nil, fmt.Errorf("unable to read dotenv file: %w", err)
+			}
+			variables, err := parseDotenv(data)
+			if err != nil {
+				return nil, fmt.Errorf("dotenv file '%s': %w", configPlan.DotenvFile, err)
+			}
+			for _, key := range knownKeys {
+				envName := envVariableName(configPlan, key)
+				if value, ok := variables[envName]; ok {
+					layers = append(layers, sourceLayer{
+						Layer:    layer,
+						Source:   ValueSource{Kind: SourceDotenv, Name: configPlan.DotenvFile + ":" + envName},
+						Settings: keySettings(key, value, delimiter),
+					})
+				}
+			}
+		case LayerEnv:
+			if configPlan.DontBindEnvToConfig {
+				continue
+			}
+			for _, key := range knownKeys {
+				envName := envVariableName(configPlan, key)
+				if value, ok := os.LookupEnv(envName); ok {
+					layers = append(layers, sourceLayer{Layer: layer, Source: ValueSource{Kind: SourceEnv, Name: envName}, Settings: keySettings(key, value, delimiter)})
+				}
+			}
+		case LayerFlags:
+			if configPlan.DontBindFlagsToConfig {
+				continue
+			}
+			for _, key := range sortedFlagKeys(bindFlags) {
+				if flag := bindFlags[key]; flag.Changed {
+					layers = append(layers, sourceLayer{Layer: layer, Source: ValueSource{Kind: SourceFlag, Name: flag.Name}, Settings: keySettings(key, flagValue(flag, false), delimiter)})
+				}
+			}
+		case LayerSet:
+			for _, key := range sortedKeys(overrides) {
+				// Overrides of list items (key[N]=value) already hold the whole list
+				source := result.overrides[key]
+				expression, _, _ := strings.Cut(source.Name, "=")
+				layers = append(layers, sourceLayer{
+					Layer:    layer,
+					Source:   source,
+					Settings: keySettings(key, overrides[key], delimiter),
+					Replace:  strings.Contains(expression, "["),
+				})
+			}
+		default:
+			values, err := configPlan.CustomSources[string(layer)]()
+			if err != nil {
+				return nil, fmt.Errorf("custom config source '%s': %w", layer, err)
+			}
+			settings := map[string]interface{}{}
+			for key, value := range values {
+				setKey(settings, key, value, delimiter)
+			}
+			layers = append(layers, sourceLayer{Layer: layer, Source: ValueSource{Kind: SourceCustom, Name: string(layer)}, Settings: settings})
+		}
+	}
+	return layers, nil
+}
+
+// mergeSourceLayers merges all layers in order of precedence according to merge strategies.
+// Default lists are fallbacks: they are replaced by lists of other layers instead of being merged with them
+func mergeSourceLayers(configPlan *ConfigurePlan, cfgStruct interface{}, layers []sourceLayer) (map[string]interface{}, error) {
+	mergeStrategies, err := collectMergeStrategies(configPlan, cfgStruct)
+	if err != nil {
+		return nil, err
+	}
+	delimiter := configPlan.ConfigParsingRules.keyDelimiter()
+	merged := map[string]interface{}{}
+	fromDefaults := map[string]bool{} // Keys with list strategies holding values of the defaults layer
+	for _, layer := range layers {
+		var strategies map[string]MergeStrategy
+		if !layer.Replace && layer.Layer != LayerDefaults {
+			strategies = map[string]MergeStrategy{}
+			for key, strategy := range mergeStrategies {
+				if !fromDefaults[key] {
+					strategies[key] = strategy
+				}
+			}
+		}
+		mergeSettings(merged, copySettings(layer.Settings), "", strategies, delimiter)
+		for key, strategy := range mergeStrategies {
+			if strategy == MergeReplace || strategy == MergeDeep {
+				continue
+			}
+			if _, ok := lookupKey(layer.Settings, key, delimiter); ok {
+				fromDefaults[key] = layer.Layer == LayerDefaults
+			}
+		}
+	}
+	return merged, nil
+}
+
+// applyMergeStrategies merges values of keys with list merge strategies from all layers and sets them in result.Viper,
+// so defaults, env, flags and overrides are merged the same way as with explicit SourcePrecedence
+func applyMergeStrategies(result *ConfigurationResult, layers []sourceLayer) error {
+	mergeStrategies, err := collectMergeStrategies(result.ConfigurePlan, result.cfgStruct)
+	if err != nil {
+		return err
+	}
+	merged, err := mergeSourceLayers(result.ConfigurePlan, result.cfgStruct, layers)
+	if err != nil {
+		return err
+	}
+	for key, strategy := range mergeStrategies {
+		if strategy == MergeReplace || strategy == MergeDeep {
+			continue
+		}
+		if value, ok := lookupKey(merged, key, result.ConfigurePlan.ConfigParsingRules.keyDelimiter()); ok {
+			result.Viper.Set(key, value)
+		}
+	}
+	return nil
+}
+
+// layersProvenance finds a source of every effective config key: the source with the highest precedence that has the key
+func layersProvenance(keys []string, layers []sourceLayer, delimiter string) map[string]ValueSource {
+	provenance := map[string]ValueSource{}
+	for _, key := range keys {
+		provenance[key] = ValueSource{Kind: SourceDefault}
+		for idx := len(layers) - 1; idx >= 0; idx-- {
+			if _, ok := lookupKey(layers[idx].Settings, key, delimiter); ok {
+				provenance[key] = layers[idx].Source
+				break
+			}
+		}
+	}
+	return provenance
+}
+
+// applyExplicitPrecedence replaces viper precedence with ConfigurePlan.SourcePrecedence:
+// all layers are merged explicitly and result.Viper is replaced with a new instance holding only the merged settings
+// (without config files, env and flag bindings of viper), so unlisted layers don't leak into it
+func applyExplicitPrecedence(rootCmd *cobra.Command, result *ConfigurationResult, bindFlags map[string]*pflag.Flag, secretKeys map[string]bool) error {
+	configPlan := result.ConfigurePlan
+	delimiter := configPlan.ConfigParsingRules.keyDelimiter()
+	layers, err := collectSourceLayers(result, bindFlags, nil)
+	if err != nil {
+		return err
+	}
+	base, err := mergeSourceLayers(configPlan, result.cfgStruct, layers)
+	if err != nil {
+		return err
+	}
+
+	// Overrides may change items of lists, so they are applied to all other layers merged
+	overridesViper := newViper(delimiter)
+	if err := overridesViper.MergeConfigMap(base); err != nil {
+		return err
+	}
+	if result.overrides, err = applyOverrideFlags(rootCmd, configPlan, overridesViper, secretKeys); err != nil {
+		return err
+	}
+	overrides := map[string]interface{}{}
+	for key := range result.overrides {
+		overrides[key] = overridesViper.Get(key)
+	}
+
+	if result.layers, err = collectSourceLayers(result, bindFlags, overrides); err != nil {
+		return err
+	}
+	merged, err := mergeSourceLayers(configPlan, result.cfgStruct, result.layers)
+	if err != nil {
+		return err
+	}
+	vp := newViper(delimiter)
+	if err := vp.MergeConfigMap(merged); err != nil {
+		return err
+	}
+	result.Viper = vp
+	result.Provenance = layersProvenance(result.Viper.AllKeys(), result.layers, delimiter)
+	return nil
+}
+
+// knownConfigKeys returns leaf keys that environment variables are looked up for:
+// keys of defaults, config files, bound flags and cfgStruct fields
+func knownConfigKeys(result *ConfigurationResult, bindFlags map[string]*pflag.Flag) []string {
+	delimiter := result.ConfigurePlan.ConfigParsingRules.keyDelimiter()
+	keys := map[string]interface{}{}
+	flattenValues(result.defaultConfig, "", keys, delimiter)
+	for _, key := range result.Viper.AllKeys() {
+		keys[key] = nil
+	}
+	for key := range bindFlags {
+		keys[strings.ToLower(key)] = nil
+	}
+	walkConfigStruct(result.cfgStruct, delimiter, func(field configStructField) bool {
+		if derefType(field.Field.Type).Kind() != reflect.Struct {
+			keys[field.Key] = nil
+		}
+		return true
+	})
+	return sortedKeys(keys)
+}
+
+// keySettings returns nested settings with a single key
+func keySettings(key string, value interface{}, delimiter string) map[string]interface{} {
+	settings := map[string]interface{}{}
+	setKey(settings, key, value, delimiter)
+	return settings
+}
+
+// flagValue returns a value of the flag (or its default value) the same way viper reads bound flags
+func flagValue(flag *pflag.Flag, defaultValue bool) interface{} {
+	value := flag.Value.String()
+	if defaultValue {
+		value = flag.DefValue
+	}
+	if sliceValue, ok := flag.Value.(pflag.SliceValue); ok {
+		if !defaultValue {
+			return sliceValue.GetSlice()
+		}
+		if value = strings.Trim(value, "[]"); value == "" {
+			return []string{}
+		}
+		return strings.Split(value, ",")
+	}
+	switch flag.Value.Type() {
+	case "int", "int8", "int16", "int32", "int64":
+		return cast.ToInt(value)
+	case "bool":
+		return cast.ToBool(value)
+	}
+	return value
+}
+
+func sortedFlagKeys(flags map[string]*pflag.Flag) []string {
+	keys := make([]string, 0, len(flags))
+	for key := range flags {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
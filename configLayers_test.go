@@ -0,0 +1,28 @@
+package xcommon
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeSourceLayersDefaultLists(t *testing.T) {
+	var cfg struct {
+		Hosts []string `merge:"append"`
+		Tags  []string `merge:"unique-append"`
+	}
+	layers := []sourceLayer{
+		{Layer: LayerDefaults, Settings: map[string]interface{}{"hosts": []interface{}{"default"}, "tags": []interface{}{"x"}}},
+		{Layer: LayerFiles, Settings: map[string]interface{}{"hosts": []interface{}{"a"}}},
+		{Layer: LayerFiles, Settings: map[string]interface{}{"hosts": []interface{}{"b"}}},
+	}
+	merged, err := mergeSourceLayers(&ConfigurePlan{}, &cfg, layers)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []interface{}{"a", "b"}; !reflect.DeepEqual(merged["hosts"], want) {
+		t.Errorf("got hosts %v, want %v", merged["hosts"], want)
+	}
+	if want := []interface{}{"x"}; !reflect.DeepEqual(merged["tags"], want) {
+		t.Errorf("got tags %v, want %v", merged["tags"], want)
+	}
+}
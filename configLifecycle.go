@@ -0,0 +1,124 @@
+package xcommon
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ConfigDefaulter is implemented by config structures (root or nested) that set their defaults in code.
+// SetDefaults is called before unmarshalling, so config values override defaults. Structures allocated from config
+// (behind nil pointers, items of lists and maps) get their defaults right before they are filled
+type ConfigDefaulter interface {
+	SetDefaults()
+}
+
+// ConfigNormalizer is implemented by config structures that normalize fields (lowercasing, trimming, derived values).
+// Normalize is called after unmarshalling and before validation
+type ConfigNormalizer interface {
+	Normalize() error
+}
+
+// ConfigValidator is implemented by config structures with their own invariants.
+// Validate is called after normalization and after declarative constraints of the structure fields
+type ConfigValidator interface {
+	Validate() error
+}
+
+// setConfigDefaults calls SetDefaults methods of cfgStruct and its nested structures depth-first
+func setConfigDefaults(cfgStruct interface{}, delimiter string) {
+	walkConfigTreeValues(cfgStruct, delimiter, func(value reflect.Value, key string) error {
+		if defaulter, ok := configInterface[ConfigDefaulter](value); ok {
+			defaulter.SetDefaults()
+		}
+		return nil
+	})
+}
+
+// normalizeConfig calls Normalize methods of cfgStruct and its nested structures depth-first. All errors are aggregated
+func normalizeConfig(cfgStruct interface{}, delimiter string) error {
+	errs := walkConfigTreeValues(cfgStruct, delimiter, func(value reflect.Value, key string) error {
+		if normalizer, ok := configInterface[ConfigNormalizer](value); ok {
+			return keyError(key, normalizer.Normalize())
+		}
+		return nil
+	})
+	return errors.Join(errs...)
+}
+
+// walkConfigTreeValues calls walkConfigValues for cfgStruct and every config section
+func walkConfigTreeValues(cfgStruct interface{}, delimiter string, fn func(value reflect.Value, key string) error) []error {
+	var errs []error
+	for _, root := range configStructRoots(cfgStruct) {
+		errs = append(errs, walkConfigValues(reflect.ValueOf(root.Struct), root.Prefix, delimiter, fn)...)
+	}
+	return errs
+}
+
+// walkConfigValues calls fn for every structure in value depth-first: nested structures (including items of lists and maps)
+// first, then the structure itself. Returns all errors of fn
+func walkConfigValues(value reflect.Value, key string, delimiter string, fn func(value reflect.Value, key string) error) []error {
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	var errs []error
+	switch value.Kind() {
+	case reflect.Struct:
+		t := value.Type()
+		for idx := 0; idx < t.NumField(); idx++ {
+			f := t.Field(idx)
+			if !f.IsExported() {
+				continue
+			}
+			name, squash := configFieldName(f)
+			if name == "-" {
+				continue
+			}
+			fieldKey := key
+			if !squash {
+				fieldKey = joinKey(key, name, delimiter)
+			}
+			errs = append(errs, walkConfigValues(value.Field(idx), fieldKey, delimiter, fn)...)
+		}
+		if err := fn(value, key); err != nil {
+			errs = append(errs, err)
+		}
+	case reflect.Slice, reflect.Array:
+		for idx := 0; idx < value.Len(); idx++ {
+			errs = append(errs, walkConfigValues(value.Index(idx), fmt.Sprintf("%s[%d]", key, idx), delimiter, fn)...)
+		}
+	case reflect.Map:
+		iter := value.MapRange()
+		for iter.Next() {
+			errs = append(errs, walkConfigValues(iter.Value(), joinKey(key, fmt.Sprint(iter.Key().Interface()), delimiter), delimiter, fn)...)
+		}
+	}
+	return errs
+}
+
+// configInterface returns I implemented by the value or by a pointer to it
+func configInterface[I any](value reflect.Value) (I, bool) {
+	if value.CanAddr() {
+		if ret, ok := value.Addr().Interface().(I); ok {
+			return ret, true
+		}
+	}
+	if value.CanInterface() {
+		if ret, ok := value.Interface().(I); ok {
+			return ret, true
+		}
+	}
+	var zero I
+	return zero, false
+}
+
+// keyError prefixes an error with a config key. nil stays nil
+func keyError(key string, err error) error {
+	if err == nil || key == "" {
+		return err
+	}
+	return fmt.Errorf("config key '%s': %w", key, err)
+}
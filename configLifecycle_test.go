@@ -0,0 +1,45 @@
+package xcommon
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+type lifecycleTestServer struct {
+	Host string
+	Port int
+}
+
+func (server *lifecycleTestServer) SetDefaults() {
+	server.Port = 80
+}
+
+type lifecycleTestConfig struct {
+	Main    *lifecycleTestServer
+	Servers []lifecycleTestServer
+	ByName  map[string]*lifecycleTestServer `mapstructure:"by_name"`
+}
+
+func TestSetDefaultsOfAllocatedStructures(t *testing.T) {
+	vp := viper.New()
+	vp.Set("main", map[string]interface{}{"host": "a"})
+	vp.Set("servers", []interface{}{map[string]interface{}{"host": "b"}, map[string]interface{}{"host": "c", "port": 8080}})
+	vp.Set("by_name", map[string]interface{}{"d": map[string]interface{}{"host": "d"}})
+
+	var cfg lifecycleTestConfig
+	setConfigDefaults(&cfg, ".")
+	if err := vp.Unmarshal(&cfg, viper.DecodeHook(configDecodeHook(&ConfigurePlan{}))); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Main == nil || *cfg.Main != (lifecycleTestServer{Host: "a", Port: 80}) {
+		t.Errorf("got main %+v", cfg.Main)
+	}
+	want := []lifecycleTestServer{{Host: "b", Port: 80}, {Host: "c", Port: 8080}}
+	if len(cfg.Servers) != 2 || cfg.Servers[0] != want[0] || cfg.Servers[1] != want[1] {
+		t.Errorf("got servers %+v, want %+v", cfg.Servers, want)
+	}
+	if server := cfg.ByName["d"]; server == nil || *server != (lifecycleTestServer{Host: "d", Port: 80}) {
+		t.Errorf("got by_name %+v", server)
+	}
+}
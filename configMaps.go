@@ -0,0 +1,141 @@
+package xcommon
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// newViper creates a viper instance with the key delimiter (see ViperConfig.KeyDelimiter)
+func newViper(delimiter string) *viper.Viper {
+	return viper.NewWithOptions(viper.KeyDelimiter(delimiter))
+}
+
+// splitKey splits a dotted config key into lowercased path components
+func splitKey(key string, delimiter string) []string {
+	return strings.Split(strings.ToLower(key), delimiter)
+}
+
+// parentKey returns a dotted key without its last component
+func parentKey(key string, delimiter string) (string, bool) {
+	idx := strings.LastIndex(key, delimiter)
+	if idx < 0 {
+		return "", false
+	}
+	return key[:idx], true
+}
+
+// lookupKey returns a value from a nested settings map by dotted key (case-insensitive)
+func lookupKey(settings map[string]interface{}, key string, delimiter string) (interface{}, bool) {
+	var current interface{} = settings
+	for _, part := range splitKey(key, delimiter) {
+		m, ok := toStringMap(current)
+		if !ok {
+			return nil, false
+		}
+		found := false
+		for k, v := range m {
+			if strings.ToLower(k) == part {
+				current = v
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// joinKey joins dotted key prefix and a key name
+func joinKey(prefix string, name string, delimiter string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + delimiter + name
+}
+
+// setKey sets a value in a nested settings map by dotted key creating intermediate maps
+func setKey(settings map[string]interface{}, key string, value interface{}, delimiter string) {
+	parts := splitKey(key, delimiter)
+	current := settings
+	for _, part := range parts[:len(parts)-1] {
+		name := findMapKey(current, part)
+		next, ok := toStringMap(current[name])
+		if !ok {
+			next = map[string]interface{}{}
+		}
+		current[name] = next
+		current = next
+	}
+	last := parts[len(parts)-1]
+	current[findMapKey(current, last)] = value
+}
+
+// deleteKey removes a value from a nested settings map by dotted key
+func deleteKey(settings map[string]interface{}, key string, delimiter string) {
+	parts := splitKey(key, delimiter)
+	current := settings
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := toStringMap(current[findMapKey(current, part)])
+		if !ok {
+			return
+		}
+		current = next
+	}
+	delete(current, findMapKey(current, parts[len(parts)-1]))
+}
+
+// findMapKey returns an existing map key matching name case-insensitively or name itself
+func findMapKey(m map[string]interface{}, name string) string {
+	if _, ok := m[name]; ok {
+		return name
+	}
+	for k := range m {
+		if strings.ToLower(k) == name {
+			return k
+		}
+	}
+	return name
+}
+
+// toStringMap converts maps produced by different config decoders to map[string]interface{}
+func toStringMap(value interface{}) (map[string]interface{}, bool) {
+	switch m := value.(type) {
+	case map[string]interface{}:
+		return m, true
+	case map[interface{}]interface{}:
+		ret := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			ret[fmt.Sprint(k)] = v
+		}
+		return ret, true
+	default:
+		return nil, false
+	}
+}
+
+// copySettings makes a deep copy of a nested settings map
+func copySettings(settings map[string]interface{}) map[string]interface{} {
+	ret := make(map[string]interface{}, len(settings))
+	for k, v := range settings {
+		ret[k] = copyValue(v)
+	}
+	return ret
+}
+
+func copyValue(value interface{}) interface{} {
+	if m, ok := toStringMap(value); ok {
+		return copySettings(m)
+	}
+	if list, ok := value.([]interface{}); ok {
+		ret := make([]interface{}, len(list))
+		for idx, item := range list {
+			ret[idx] = copyValue(item)
+		}
+		return ret
+	}
+	return value
+}
@@ -0,0 +1,95 @@
+package xcommon
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/cast"
+)
+
+// ConfigMigration upgrades raw settings of a single config file to the next schema version.
+// Keys in settings are lowercased. Nested sections are map[string]interface{}
+type ConfigMigration func(settings map[string]interface{}) error
+
+// latestConfigVersion returns the latest config schema version known to configPlan
+func latestConfigVersion(configPlan *ConfigurePlan) int {
+	return len(configPlan.ConfigMigrations)
+}
+
+// migrateConfigSettings applies all pending migrations to settings in place.
+// unversioned is the version of settings without version key. Returns version of settings before migration
+func migrateConfigSettings(configPlan *ConfigurePlan, settings map[string]interface{}, unversioned int) (int, error) {
+	latest := latestConfigVersion(configPlan)
+	if configPlan.ConfigVersionKey == "" {
+		return latest, nil
+	}
+
+	delimiter := configPlan.ConfigParsingRules.keyDelimiter()
+	version := unversioned
+	if rawVersion, ok := lookupKey(settings, configPlan.ConfigVersionKey, delimiter); ok {
+		parsedVersion, err := cast.ToIntE(rawVersion)
+		if err != nil {
+			return 0, fmt.Errorf("invalid config version '%v' in key '%s': %w", rawVersion, configPlan.ConfigVersionKey, err)
+		}
+		version = parsedVersion
+	}
+	if version < 0 || version > latest {
+		return version, fmt.Errorf("unsupported config version %d (latest known version is %d)", version, latest)
+	}
+	if version == latest {
+		return version, nil
+	}
+
+	for idx := version; idx < latest; idx++ {
+		if err := configPlan.ConfigMigrations[idx](settings); err != nil {
+			return version, fmt.Errorf("unable to migrate config from version %d to %d: %w", idx, idx+1, err)
+		}
+	}
+	setKey(settings, configPlan.ConfigVersionKey, latest, delimiter)
+	return version, nil
+}
+
+// configMigrationsHook returns config file hook that migrates every config file to the latest schema version.
+// The primary (first) config file without version key is considered to be the very first version.
+// Overlay files and inline config without version key usually set a few keys only, so they are considered to be the latest version
+func configMigrationsHook(configPlan *ConfigurePlan) configFileHook {
+	primary := true
+	return func(file *configFile) error {
+		unversioned := latestConfigVersion(configPlan)
+		if primary && file.Kind == SourceFile {
+			unversioned = 0
+		}
+		primary = false
+		if _, err := migrateConfigSettings(configPlan, file.Settings, unversioned); err != nil {
+			return fmt.Errorf("config file '%s': %w", file.Path, err)
+		}
+		return nil
+	}
+}
+
+// settingsChanges collects changes between nested settings as dotted keys for setFileValues.
+// Removed keys are nil, changed and added keys have values of after
+func settingsChanges(before map[string]interface{}, after map[string]interface{}, prefix string, out map[string]interface{}, delimiter string) {
+	for key, beforeValue := range before {
+		afterKey := findMapKey(after, strings.ToLower(key))
+		afterValue, ok := after[afterKey]
+		fullKey := joinKey(prefix, strings.ToLower(key), delimiter)
+		if !ok {
+			out[fullKey] = nil
+			continue
+		}
+		beforeMap, beforeIsMap := toStringMap(beforeValue)
+		afterMap, afterIsMap := toStringMap(afterValue)
+		if beforeIsMap && afterIsMap {
+			settingsChanges(beforeMap, afterMap, fullKey, out, delimiter)
+		} else if !reflect.DeepEqual(beforeValue, afterValue) {
+			out[fullKey] = afterValue
+		}
+	}
+	for key, afterValue := range after {
+		if _, ok := before[findMapKey(before, strings.ToLower(key))]; !ok {
+			out[joinKey(prefix, strings.ToLower(key), delimiter)] = afterValue
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package xcommon
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func TestConfigMigrationsOverlayWithoutVersion(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	dir := t.TempDir()
+	files := map[string]string{
+		"base.yaml":    "version: 1\nserver:\n  host: a\n",
+		"overlay.yaml": "server:\n  port: 8\n",
+		"old.yaml":     "server:\n  host: b\n",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var migrated []string
+	plan := &ConfigurePlan{
+		ConfigVersionKey: "version",
+		ConfigMigrations: []ConfigMigration{
+			func(settings map[string]interface{}) error {
+				migrated = append(migrated, "0->1")
+				return nil
+			},
+			func(settings map[string]interface{}) error {
+				migrated = append(migrated, "1->2")
+				return nil
+			},
+		},
+	}
+	rootCmd := &cobra.Command{Use: "app"}
+
+	plan.ConfigParsingRules.ConcreeteFilePaths = []string{filepath.Join(dir, "base.yaml"), filepath.Join(dir, "overlay.yaml")}
+	result, err := configure(rootCmd, plan, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrated) != 1 || migrated[0] != "1->2" {
+		t.Errorf("got migrations %v, want only 1->2 of the base file", migrated)
+	}
+	if result.Viper.GetInt("server.port") != 8 || result.Viper.GetInt("version") != 2 {
+		t.Errorf("got port %v and version %v", result.Viper.Get("server.port"), result.Viper.Get("version"))
+	}
+
+	// The primary file without version is the very first version
+	viper.Reset()
+	migrated = nil
+	plan.ConfigParsingRules.ConcreeteFilePaths = []string{filepath.Join(dir, "old.yaml"), filepath.Join(dir, "overlay.yaml")}
+	if _, err := configure(rootCmd, plan, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(migrated) != 2 {
+		t.Errorf("got migrations %v, want 0->1 and 1->2 of the primary file", migrated)
+	}
+}
@@ -0,0 +1,201 @@
+package xcommon
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// registerOverrideFlags registers --set and --set-file flags with config keys completion
+func registerOverrideFlags(rootCmd *cobra.Command, configPlan *ConfigurePlan, cfgStruct interface{}) {
+	completeKeys := func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		var keys []string
+		walkConfigStruct(cfgStruct, configPlan.ConfigParsingRules.keyDelimiter(), func(field configStructField) bool {
+			if strings.HasPrefix(field.Key, strings.ToLower(toComplete)) {
+				keys = append(keys, field.Key+"=")
+			}
+			return true
+		})
+		return keys, cobra.ShellCompDirectiveNoSpace | cobra.ShellCompDirectiveNoFileComp
+	}
+	if configPlan.SetFlag != "" {
+		rootCmd.PersistentFlags().StringArray(configPlan.SetFlag, nil,
+			"override config value: key=value (value is parsed as YAML, list items are set as key[0]=value)")
+		_ = rootCmd.RegisterFlagCompletionFunc(configPlan.SetFlag, completeKeys)
+	}
+	if configPlan.SetFileFlag != "" {
+		rootCmd.PersistentFlags().StringArray(configPlan.SetFileFlag, nil,
+			"override config value with contents of a file: key=path")
+		_ = rootCmd.RegisterFlagCompletionFunc(configPlan.SetFileFlag, completeKeys)
+	}
+}
+
+// applyOverrideFlags applies --set and --set-file flags to viper. --set-file overrides are applied last.
+// Returns provenance of overridden keys. Permissions of --set-file files with secret keys are checked
+func applyOverrideFlags(rootCmd *cobra.Command, configPlan *ConfigurePlan, vp *viper.Viper, secretKeys map[string]bool) (map[string]ValueSource, error) {
+	delimiter := configPlan.ConfigParsingRules.keyDelimiter()
+	overrides := map[string]ValueSource{}
+	if configPlan.SetFlag != "" {
+		expressions, _ := rootCmd.Flags().GetStringArray(configPlan.SetFlag)
+		for _, expression := range expressions {
+			key, rawValue, err := splitOverride(expression)
+			if err != nil {
+				return nil, fmt.Errorf("--%s %w", configPlan.SetFlag, err)
+			}
+			var value interface{}
+			if err := yaml.Unmarshal([]byte(rawValue), &value); err != nil || value == nil {
+				value = rawValue // Not a YAML value, use as plain string
+			}
+			baseKey, err := setOverride(vp, key, value, delimiter)
+			if err != nil {
+				return nil, fmt.Errorf("--%s %s: %w", configPlan.SetFlag, expression, err)
+			}
+			overrides[baseKey] = ValueSource{Kind: SourceSet, Name: expression}
+		}
+	}
+	if configPlan.SetFileFlag != "" {
+		expressions, _ := rootCmd.Flags().GetStringArray(configPlan.SetFileFlag)
+		for _, expression := range expressions {
+			key, path, err := splitOverride(expression)
+			if err != nil {
+				return nil, fmt.Errorf("--%s %w", configPlan.SetFileFlag, err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("--%s %s: %w", configPlan.SetFileFlag, expression, err)
+			}
+			if isSecretKey(secretKeys, key, delimiter) {
+				if err := checkSecretFile(configPlan.ConfigParsingRules.SecretFilePermissions, path, "--"+configPlan.SetFileFlag+" file"); err != nil {
+					return nil, err
+				}
+			}
+			baseKey, err := setOverride(vp, key, string(data), delimiter)
+			if err != nil {
+				return nil, fmt.Errorf("--%s %s: %w", configPlan.SetFileFlag, expression, err)
+			}
+			overrides[baseKey] = ValueSource{Kind: SourceSet, Name: expression}
+		}
+	}
+	return overrides, nil
+}
+
+func splitOverride(expression string) (string, string, error) {
+	key, value, ok := strings.Cut(expression, "=")
+	if !ok || strings.TrimSpace(key) == "" {
+		return "", "", fmt.Errorf("'%s' should be in key=value form", expression)
+	}
+	return strings.ToLower(strings.TrimSpace(key)), value, nil
+}
+
+// keySegment is a part of an override key: a map key or a list index
+type keySegment struct {
+	Name  string
+	Index int // Used if Name is empty
+}
+
+// setOverride sets value of key in viper overrides layer. Key may contain list indices like "a.b[0].c".
+// Returns the key that was actually set in viper (the part before the first list index)
+func setOverride(vp *viper.Viper, key string, value interface{}, delimiter string) (string, error) {
+	bracket := strings.Index(key, "[")
+	if bracket < 0 {
+		vp.Set(key, value)
+		return key, nil
+	}
+	baseKey := key[:bracket]
+	segments, err := parseKeySegments(key[bracket:], delimiter)
+	if err != nil {
+		return "", err
+	}
+	newValue, err := setSegmentsValue(copyValue(vp.Get(baseKey)), segments, value)
+	if err != nil {
+		return "", err
+	}
+	vp.Set(baseKey, newValue)
+	return baseKey, nil
+}
+
+// parseKeySegments parses a key tail like "[0].name[2]"
+func parseKeySegments(tail string, delimiter string) ([]keySegment, error) {
+	var segments []keySegment
+	for tail != "" {
+		switch {
+		case tail[0] == '[':
+			end := strings.Index(tail, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed '[' in key")
+			}
+			index, err := strconv.Atoi(tail[1:end])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid list index '%s'", tail[1:end])
+			}
+			segments = append(segments, keySegment{Index: index})
+			tail = tail[end+1:]
+		case strings.HasPrefix(tail, delimiter):
+			tail = tail[len(delimiter):]
+		default:
+			end := len(tail)
+			if idx := strings.Index(tail, delimiter); idx >= 0 {
+				end = idx
+			}
+			if idx := strings.Index(tail, "["); idx >= 0 && idx < end {
+				end = idx
+			}
+			segments = append(segments, keySegment{Name: tail[:end]})
+			tail = tail[end:]
+		}
+	}
+	return segments, nil
+}
+
+// setSegmentsValue returns container with value set by segments path. Lists can be extended by one item
+func setSegmentsValue(container interface{}, segments []keySegment, value interface{}) (interface{}, error) {
+	if len(segments) == 0 {
+		return value, nil
+	}
+	segment := segments[0]
+	if segment.Name != "" {
+		m, ok := toStringMap(container)
+		if !ok {
+			if container != nil {
+				return nil, fmt.Errorf("'%s' is not a map", segment.Name)
+			}
+			m = map[string]interface{}{}
+		}
+		name := findMapKey(m, segment.Name)
+		newValue, err := setSegmentsValue(m[name], segments[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		m[name] = newValue
+		return m, nil
+	}
+
+	var list []interface{}
+	if container != nil {
+		rv := reflect.ValueOf(container)
+		if rv.Kind() != reflect.Slice {
+			return nil, fmt.Errorf("value is not a list")
+		}
+		for idx := 0; idx < rv.Len(); idx++ {
+			list = append(list, rv.Index(idx).Interface())
+		}
+	}
+	if segment.Index > len(list) {
+		return nil, fmt.Errorf("list index %d is out of range (list length is %d)", segment.Index, len(list))
+	}
+	if segment.Index == len(list) {
+		list = append(list, nil)
+	}
+	newValue, err := setSegmentsValue(list[segment.Index], segments[1:], value)
+	if err != nil {
+		return nil, err
+	}
+	list[segment.Index] = newValue
+	return list, nil
+}
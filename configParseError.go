@@ -0,0 +1,151 @@
+package xcommon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const parseErrorExcerptLines = 2 // Number of source lines shown before and after the failed line
+
+// ConfigParseError is returned when a config file exists but can't be parsed
+type ConfigParseError struct {
+	Path    string // Path to the config file
+	Line    int    // 1-based line of the error. 0 if unknown
+	Column  int    // 1-based column of the error. 0 if unknown
+	Excerpt string // Source lines around the error. Empty if line is unknown
+	Err     error  // Original parser error
+}
+
+func (e *ConfigParseError) Error() string {
+	location := e.Path
+	if e.Line > 0 {
+		location += ":" + strconv.Itoa(e.Line)
+		if e.Column > 0 {
+			location += ":" + strconv.Itoa(e.Column)
+		}
+	}
+	msg := fmt.Sprintf("unable to parse config file %s: %s", location, e.Err)
+	if e.Excerpt != "" {
+		msg += "\n" + e.Excerpt
+	}
+	return msg
+}
+
+func (e *ConfigParseError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	lineColumnRe  = regexp.MustCompile(`(?i)\bline:? (\d+)(?:,? column:? (\d+))?`)
+	hclPositionRe = regexp.MustCompile(`\bAt (\d+):(\d+)`)
+)
+
+// newConfigParseError builds ConfigParseError with error position extracted from the parser error
+func newConfigParseError(path string, data []byte, err error) *ConfigParseError {
+	var viperErr viper.ConfigParseError
+	if errors.As(err, &viperErr) {
+		err = viperErr.Unwrap()
+	}
+	parseErr := &ConfigParseError{Path: path, Err: err}
+
+	var positionErr interface{ Position() (int, int) } // go-toml errors
+	offset, isJSONErr := jsonErrorOffset(err)
+	switch {
+	case isJSONErr:
+		parseErr.Line, parseErr.Column = offsetToPosition(data, int64(max(offset-1, 0))) // Offset is reported after the failed byte
+	case errors.As(err, &positionErr):
+		parseErr.Line, parseErr.Column = positionErr.Position()
+	default:
+		msg := err.Error()
+		if match := hclPositionRe.FindStringSubmatch(msg); match != nil {
+			parseErr.Line, _ = strconv.Atoi(match[1])
+			parseErr.Column, _ = strconv.Atoi(match[2])
+		} else if match := lineColumnRe.FindStringSubmatch(msg); match != nil {
+			parseErr.Line, _ = strconv.Atoi(match[1])
+			parseErr.Column, _ = strconv.Atoi(match[2])
+		}
+	}
+	parseErr.Excerpt = sourceExcerpt(data, parseErr.Line, parseErr.Column)
+	return parseErr
+}
+
+// newConfigParseErrorAt builds ConfigParseError at a known byte offset of data
+func newConfigParseErrorAt(path string, data []byte, offset int, err error) *ConfigParseError {
+	var viperErr viper.ConfigParseError
+	if errors.As(err, &viperErr) {
+		err = viperErr.Unwrap()
+	}
+	parseErr := &ConfigParseError{Path: path, Err: err}
+	parseErr.Line, parseErr.Column = offsetToPosition(data, int64(offset))
+	parseErr.Excerpt = sourceExcerpt(data, parseErr.Line, parseErr.Column)
+	return parseErr
+}
+
+// jsonErrorOffset returns byte offset of encoding/json error
+func jsonErrorOffset(err error) (int, bool) {
+	var jsonSyntaxErr *json.SyntaxError
+	var jsonTypeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &jsonSyntaxErr):
+		return int(jsonSyntaxErr.Offset), true
+	case errors.As(err, &jsonTypeErr):
+		return int(jsonTypeErr.Offset), true
+	}
+	return 0, false
+}
+
+// mapJSONOffset maps an offset in converted JSON back to the original document
+func mapJSONOffset(offsets []int, offset int) int {
+	if len(offsets) == 0 {
+		return 0
+	}
+	// encoding/json reports offset after the failed byte
+	return offsets[min(max(offset-1, 0), len(offsets)-1)]
+}
+
+// offsetToPosition converts a byte offset into 1-based line and column
+func offsetToPosition(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	line, column := 1, 1
+	for _, b := range data[:offset] {
+		if b == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	return line, column
+}
+
+// sourceExcerpt returns numbered source lines around line with a column marker
+func sourceExcerpt(data []byte, line int, column int) string {
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if line <= 0 || line > len(lines) {
+		return ""
+	}
+	first := max(line-parseErrorExcerptLines, 1)
+	last := min(line+parseErrorExcerptLines, len(lines))
+	width := len(strconv.Itoa(last))
+
+	out := &strings.Builder{}
+	for idx := first; idx <= last; idx++ {
+		marker := " "
+		if idx == line {
+			marker = ">"
+		}
+		fmt.Fprintf(out, "%s %*d | %s\n", marker, width, idx, strings.TrimRight(lines[idx-1], "\r"))
+		if idx == line && column > 0 {
+			fmt.Fprintf(out, "  %*s | %s^\n", width, "", strings.Repeat(" ", column-1))
+		}
+	}
+	return strings.TrimRight(out.String(), "\n")
+}
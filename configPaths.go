@@ -0,0 +1,155 @@
+package xcommon
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// Path is a filesystem path config field. It's resolved like a string field with `path:"relative"` tag:
+// relative paths from config files are resolved against the directory of that file, "~" and environment variables are expanded.
+// Options can be added with a tag: `path:"exists"`, `path:"file"`, `path:"dir"`, `path:"readable"`
+type Path string
+
+func (path Path) String() string {
+	return string(path)
+}
+
+var pathType = reflect.TypeOf(Path(""))
+
+// pathOptions are parsed options of `path:"..."` tag
+type pathOptions struct {
+	Relative bool // Resolve relative paths against the directory of the config file that supplied the value
+	Exists   bool // Path must exist
+	File     bool // Path must be a regular file
+	Dir      bool // Path must be a directory
+	Readable bool // Path must be readable by the current user
+}
+
+func parsePathOptions(field configStructField) (pathOptions, bool) {
+	tag, hasTag := field.Field.Tag.Lookup("path")
+	elemType := field.Field.Type
+	if elemType.Kind() == reflect.Slice {
+		elemType = elemType.Elem()
+	}
+	isPathType := elemType == pathType
+	if !hasTag && !isPathType {
+		return pathOptions{}, false
+	}
+	if elemType.Kind() != reflect.String {
+		return pathOptions{}, false
+	}
+
+	options := pathOptions{Relative: isPathType}
+	for _, opt := range strings.Split(tag, ",") {
+		switch strings.TrimSpace(opt) {
+		case "relative":
+			options.Relative = true
+		case "exists":
+			options.Exists = true
+		case "file":
+			options.Exists, options.File = true, true
+		case "dir":
+			options.Exists, options.Dir = true, true
+		case "readable":
+			options.Exists, options.Readable = true, true
+		}
+	}
+	return options, true
+}
+
+// resolveConfigPaths resolves and checks all path fields of cfgStruct. All errors are aggregated
+func resolveConfigPaths(result *ConfigurationResult, cfgStruct interface{}) error {
+	var errs []error
+	walkConfigStruct(cfgStruct, result.ConfigurePlan.ConfigParsingRules.keyDelimiter(), func(field configStructField) bool {
+		options, ok := parsePathOptions(field)
+		if !ok {
+			return true
+		}
+		value, ok := fieldByIndex(reflect.ValueOf(field.Root), field.Index)
+		if !ok {
+			return false
+		}
+		baseDir := ""
+		if source, ok := result.Provenance[field.Key]; ok && source.Kind == SourceFile && options.Relative {
+			baseDir = filepath.Dir(source.Name)
+		}
+		if value.Kind() == reflect.Slice {
+			for idx := 0; idx < value.Len(); idx++ {
+				errs = append(errs, resolvePathValue(value.Index(idx), fmt.Sprintf("%s[%d]", field.Key, idx), baseDir, options))
+			}
+		} else {
+			errs = append(errs, resolvePathValue(value, field.Key, baseDir, options))
+		}
+		return true
+	})
+	return errors.Join(errs...)
+}
+
+func resolvePathValue(value reflect.Value, key string, baseDir string, options pathOptions) error {
+	path := value.String()
+	if path == "" {
+		return nil
+	}
+	path = expandPath(path)
+	if baseDir != "" && !filepath.IsAbs(path) {
+		path = filepath.Join(baseDir, path)
+	}
+	value.SetString(path)
+
+	if !options.Exists {
+		return nil
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("config key '%s': %w", key, err)
+	}
+	if options.File && !stat.Mode().IsRegular() {
+		return fmt.Errorf("config key '%s': '%s' is not a regular file", key, path)
+	}
+	if options.Dir && !stat.IsDir() {
+		return fmt.Errorf("config key '%s': '%s' is not a directory", key, path)
+	}
+	if options.Readable {
+		if stat.IsDir() {
+			_, err = os.ReadDir(path)
+		} else {
+			var file *os.File
+			if file, err = os.Open(path); err == nil {
+				file.Close()
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("config key '%s': %w", key, err)
+		}
+	}
+	return nil
+}
+
+// expandPath expands environment variables and leading "~" in path
+func expandPath(path string) string {
+	path = os.ExpandEnv(path)
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
+
+// fieldByIndex is like reflect.Value.FieldByIndex but returns false on nil pointers on the way
+func fieldByIndex(value reflect.Value, index []int) (reflect.Value, bool) {
+	for _, idx := range index {
+		for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+			if value.IsNil() {
+				return reflect.Value{}, false
+			}
+			value = value.Elem()
+		}
+		value = value.Field(idx)
+	}
+	return value, true
+}
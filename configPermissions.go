@@ -0,0 +1,75 @@
+package xcommon
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// FilePermissionPolicy is a policy of permission and ownership checks of files with secrets
+type FilePermissionPolicy string
+
+const (
+	FilePermissionsIgnore FilePermissionPolicy = ""     // Files are not checked
+	FilePermissionsWarn   FilePermissionPolicy = "warn" // Unsafe files are reported with a warning
+	FilePermissionsFail   FilePermissionPolicy = "fail" // Unsafe files fail configuration
+)
+
+// checkSecretFile checks that a file with secrets is not readable by group or others
+// and is not writable by another user. Issues are reported according to the policy
+func checkSecretFile(policy FilePermissionPolicy, path string, what string) error {
+	if policy == FilePermissionsIgnore || !filePermissionsSupported || path == stdinConfigPath {
+		return nil
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil // Missing files are reported by readers
+	}
+	var issues []string
+	mode := stat.Mode().Perm()
+	if mode&0044 != 0 {
+		issues = append(issues, "readable by group or others")
+	}
+	if mode&0022 != 0 {
+		issues = append(issues, "writable by group or others")
+	}
+	if owner, ok := fileOwner(stat); ok && !isTrustedOwner(owner) {
+		issues = append(issues, fmt.Sprintf("owned by another user (uid %d)", owner))
+	}
+	if len(issues) == 0 {
+		return nil
+	}
+	message := fmt.Sprintf("%s '%s' contains secrets but is %s (mode %04o)", what, path, strings.Join(issues, ", "), mode)
+	if policy == FilePermissionsFail {
+		return fmt.Errorf("%s", message)
+	}
+	log.Warnf("%s", message)
+	return nil
+}
+
+// secretFilePermissionsHook checks permissions of config files that contain plain (not encrypted) secret values
+func secretFilePermissionsHook(policy FilePermissionPolicy, secretKeys map[string]bool, delimiter string) configFileHook {
+	return func(file *configFile) error {
+		if file.Kind != SourceFile || file.Encrypted[""] || !hasPlainSecrets(file.Settings, "", secretKeys, file.Encrypted, delimiter) {
+			return nil
+		}
+		return checkSecretFile(policy, file.Path, "config file")
+	}
+}
+
+// hasPlainSecrets checks if settings contain any secret key that is not encrypted and is not a "file:" reference
+func hasPlainSecrets(settings map[string]interface{}, prefix string, secretKeys map[string]bool, encrypted map[string]bool, delimiter string) bool {
+	for k, v := range settings {
+		key := strings.ToLower(joinKey(prefix, k, delimiter))
+		if m, ok := toStringMap(v); ok {
+			if hasPlainSecrets(m, key, secretKeys, encrypted, delimiter) {
+				return true
+			}
+		} else if _, ref := secretFileReference(v); isSecretKey(secretKeys, key, delimiter) && !encrypted[key] && !ref {
+			return true
+		}
+	}
+	return false
+}
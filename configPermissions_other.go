@@ -0,0 +1,19 @@
+//go:build !unix
+
+package xcommon
+
+import (
+	"io/fs"
+)
+
+const filePermissionsSupported = false // Modes and owners of files aren't meaningful on this platform
+
+// fileOwner is not supported on this platform, ownership isn't checked
+func fileOwner(stat fs.FileInfo) (int, bool) {
+	return 0, false
+}
+
+// isTrustedOwner is not supported on this platform
+func isTrustedOwner(uid int) bool {
+	return true
+}
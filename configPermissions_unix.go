@@ -0,0 +1,25 @@
+//go:build unix
+
+package xcommon
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+const filePermissionsSupported = true // Unix modes and owners are checked
+
+// fileOwner returns uid of the file owner
+func fileOwner(stat fs.FileInfo) (int, bool) {
+	sys, ok := stat.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return int(sys.Uid), true
+}
+
+// isTrustedOwner checks if uid is the current user or root
+func isTrustedOwner(uid int) bool {
+	return uid == os.Geteuid() || uid == 0
+}
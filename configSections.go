@@ -0,0 +1,215 @@
+package xcommon
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// ConfigSection is a config section of a reusable component (such as a DB pool or an HTTP client).
+// Components describe their sections and applications list them in ConfigurePlan.Sections
+type ConfigSection struct {
+	Path     string                                            // Dotted path of the section in the config tree (such as "database.pool")
+	Config   interface{}                                       // Pointer to the component's config structure. The section is unmarshalled into it. All cfgStruct tags are supported
+	Defaults map[string]interface{}                            // Default values with keys relative to Path
+	Flags    func(flags *pflag.FlagSet) map[string]*pflag.Flag // Registers component flags and returns flags to bind by keys relative to Path. Optional
+}
+
+// configTree is cfgStruct passed to InitCobra together with config sections of components
+type configTree struct {
+	Root     interface{}               // cfgStruct passed to InitCobra
+	Sections []*ConfigSection          // Config sections from ConfigurePlan
+	Commands map[string]*ConfigSection // Config sections of subcommands. They are decoded only when the subcommand is invoked
+}
+
+// loadedOnStart returns the part of the tree that is decoded by the initializer (without subcommand sections)
+func (tree *configTree) loadedOnStart() *configTree {
+	return &configTree{Root: tree.Root, Sections: tree.Sections}
+}
+
+// configRoot is a structure that is unmarshalled from a config subtree
+type configRoot struct {
+	Prefix string      // Dotted path of the subtree. Empty for cfgStruct
+	Struct interface{} // Pointer to the structure
+}
+
+// configStructRoots returns cfgStruct and config sections as separate structures
+func configStructRoots(cfgStruct interface{}) []configRoot {
+	var roots []configRoot
+	tree, ok := cfgStruct.(*configTree)
+	if !ok {
+		tree = &configTree{Root: cfgStruct}
+	}
+	if tree.Root != nil {
+		roots = append(roots, configRoot{Struct: tree.Root})
+	}
+	for _, section := range configStructSections(tree) {
+		if section.Config != nil {
+			roots = append(roots, configRoot{Prefix: strings.ToLower(section.Path), Struct: section.Config})
+		}
+	}
+	return roots
+}
+
+// sortedCommandPaths returns command paths of subcommand sections in a stable order
+func sortedCommandPaths(commands map[string]*ConfigSection) []string {
+	paths := make([]string, 0, len(commands))
+	for path := range commands {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// checkConfigSections rejects invalid section paths and overlapping component sections.
+// A subcommand section must not overlap component sections, but subcommands may share sections with each other
+func checkConfigSections(sections []*ConfigSection, commands map[string]*ConfigSection, delimiter string) error {
+	paths := make([]string, 0, len(sections))
+	for _, section := range sections {
+		if !validSectionPath(section.Path, delimiter) {
+			return fmt.Errorf("config section has invalid path '%s'", section.Path)
+		}
+		paths = append(paths, strings.ToLower(section.Path))
+	}
+	sort.Strings(paths)
+	for idx := 1; idx < len(paths); idx++ {
+		if sectionsOverlap(paths[idx-1], paths[idx], delimiter) {
+			return fmt.Errorf("config sections '%s' and '%s' overlap", paths[idx-1], paths[idx])
+		}
+	}
+	for _, command := range sortedCommandPaths(commands) {
+		commandPath := strings.ToLower(commands[command].Path)
+		if !validSectionPath(commandPath, delimiter) {
+			return fmt.Errorf("config section of command '%s' has invalid path '%s'", command, commands[command].Path)
+		}
+		for _, path := range paths {
+			if sectionsOverlap(path, commandPath, delimiter) || sectionsOverlap(commandPath, path, delimiter) {
+				return fmt.Errorf("config section '%s' of command '%s' overlaps config section '%s'", commandPath, command, path)
+			}
+		}
+	}
+	return nil
+}
+
+func validSectionPath(path string, delimiter string) bool {
+	return path != "" && !strings.HasPrefix(path, delimiter) && !strings.HasSuffix(path, delimiter) && !strings.Contains(path, delimiter+delimiter)
+}
+
+// sectionsOverlap checks if path equals to parent or is nested into it
+func sectionsOverlap(parent string, path string, delimiter string) bool {
+	return path == parent || strings.HasPrefix(path, parent+delimiter)
+}
+
+// configStructSections returns component and subcommand sections of the tree
+func configStructSections(tree *configTree) []*ConfigSection {
+	sections := append([]*ConfigSection{}, tree.Sections...)
+	for _, command := range sortedCommandPaths(tree.Commands) {
+		sections = append(sections, tree.Commands[command])
+	}
+	return sections
+}
+
+// composeSectionDefaults returns defaultConfig with defaults of all sections. defaultConfig is not modified
+func composeSectionDefaults(defaultConfig map[string]interface{}, sections []*ConfigSection, delimiter string) map[string]interface{} {
+	if len(sections) == 0 {
+		return defaultConfig
+	}
+	ret := make(map[string]interface{}, len(defaultConfig))
+	for key, value := range defaultConfig {
+		ret[key] = value
+	}
+	for _, section := range sections {
+		for key, value := range section.Defaults {
+			ret[joinKey(strings.ToLower(section.Path), key, delimiter)] = value
+		}
+	}
+	return ret
+}
+
+// registerSectionFlags registers flags of all sections and returns bindFlags with section flags bound to full keys
+func registerSectionFlags(rootCmd *cobra.Command, bindFlags map[string]*pflag.Flag, sections []*ConfigSection, delimiter string) map[string]*pflag.Flag {
+	ret := make(map[string]*pflag.Flag, len(bindFlags))
+	for key, flag := range bindFlags {
+		ret[key] = flag
+	}
+	for _, section := range sections {
+		if section.Flags == nil {
+			continue
+		}
+		for key, flag := range section.Flags(rootCmd.PersistentFlags()) {
+			ret[joinKey(strings.ToLower(section.Path), key, delimiter)] = flag
+		}
+	}
+	return ret
+}
+
+// registerCommandSections registers flags of subcommand sections and wraps PreRunE of the subcommands to decode and validate their sections.
+// Returns bindFlags with subcommand flags bound to full keys
+func registerCommandSections(
+	rootCmd *cobra.Command,
+	bindFlags map[string]*pflag.Flag,
+	commands map[string]*ConfigSection,
+	configurationResult **ConfigurationResult,
+	delimiter string,
+) (map[string]*pflag.Flag, error) {
+	ret := make(map[string]*pflag.Flag, len(bindFlags))
+	for key, flag := range bindFlags {
+		ret[key] = flag
+	}
+	for _, command := range sortedCommandPaths(commands) {
+		section := commands[command]
+		cmd, rest, err := rootCmd.Find(strings.Fields(command))
+		if err != nil || len(rest) > 0 || cmd == rootCmd {
+			return nil, fmt.Errorf("config section '%s': command '%s' is not found", section.Path, command)
+		}
+		if section.Flags != nil {
+			for key, flag := range section.Flags(cmd.Flags()) {
+				ret[joinKey(strings.ToLower(section.Path), key, delimiter)] = flag
+			}
+		}
+
+		preRunE, preRun := cmd.PreRunE, cmd.PreRun
+		cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+			sectionStruct := &configTree{Sections: []*ConfigSection{section}}
+			if err := checkRequiredKeys(*configurationResult, ret, sectionStruct); err != nil {
+				return err
+			}
+			if err := decodeConfigStruct(*configurationResult, sectionStruct); err != nil {
+				return err
+			}
+			(*configurationResult).decoded = append((*configurationResult).decoded, sectionStruct)
+			if preRunE != nil {
+				return preRunE(cmd, args)
+			}
+			if preRun != nil {
+				preRun(cmd, args)
+			}
+			return nil
+		}
+	}
+	return ret, nil
+}
+
+// decodeConfigSection unmarshals a subtree of effective settings into a section structure the same way viper does
+func decodeConfigSection(result *ConfigurationResult, root configRoot) error {
+	subtree, _ := lookupKey(result.Viper.AllSettings(), root.Prefix, result.ConfigurePlan.ConfigParsingRules.keyDelimiter())
+	if subtree == nil {
+		return nil
+	}
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       configDecodeHook(result.ConfigurePlan),
+		WeaklyTypedInput: true,
+		Result:           root.Struct,
+	})
+	if err != nil {
+		return err
+	}
+	if err := decoder.Decode(subtree); err != nil {
+		return fmt.Errorf("config section '%s': %w", root.Prefix, err)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package xcommon
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/spf13/cast"
+)
+
+// ConfigFingerprint is a stable hash of effective configuration.
+// Two configurations with the same effective values have the same fingerprint regardless of sources, key order and value types ("80" == 80)
+type ConfigFingerprint struct {
+	Settings string `json:"settings"`          // SHA-256 of non-secret effective settings
+	Secrets  string `json:"secrets,omitempty"` // SHA-256 of secret effective settings. Empty if there are no secrets
+}
+
+// ConfigSnapshot is an exportable snapshot of effective configuration with provenance
+type ConfigSnapshot struct {
+	Fingerprint ConfigFingerprint        `json:"fingerprint"` // Fingerprint of the configuration
+	Files       []string                 `json:"files"`       // Loaded config files
+	Values      map[string]SnapshotValue `json:"values"`      // Effective values by dotted key
+}
+
+// SnapshotValue is an effective config value with its source
+type SnapshotValue struct {
+	Value  interface{} `json:"value"`  // Effective value. Secrets are redacted
+	Source string      `json:"source"` // Source of the value (see ValueSource)
+}
+
+// Fingerprint returns a stable hash of effective configuration. Secret values are hashed separately
+func (result *ConfigurationResult) Fingerprint() ConfigFingerprint {
+	secretKeys := collectSecretKeys(result.ConfigurePlan, result.cfgStruct)
+	delimiter := result.ConfigurePlan.ConfigParsingRules.keyDelimiter()
+	settings := map[string]interface{}{}
+	secrets := map[string]interface{}{}
+	for _, key := range result.Viper.AllKeys() {
+		if isSecretKey(secretKeys, key, delimiter) {
+			secrets[key] = normalizeValue(result.Viper.Get(key))
+		} else {
+			settings[key] = normalizeValue(result.Viper.Get(key))
+		}
+	}
+	fingerprint := ConfigFingerprint{Settings: hashSettings(settings)}
+	if len(secrets) > 0 {
+		fingerprint.Secrets = hashSettings(secrets)
+	}
+	return fingerprint
+}
+
+// Snapshot returns effective configuration with provenance of every value. Secret values are redacted
+func (result *ConfigurationResult) Snapshot() ConfigSnapshot {
+	secretKeys := collectSecretKeys(result.ConfigurePlan, result.cfgStruct)
+	snapshot := ConfigSnapshot{
+		Fingerprint: result.Fingerprint(),
+		Files:       append([]string{}, result.ParsedConfigs...),
+		Values:      map[string]SnapshotValue{},
+	}
+	for _, key := range result.Viper.AllKeys() {
+		value := result.Viper.Get(key)
+		if isSecretKey(secretKeys, key, result.ConfigurePlan.ConfigParsingRules.keyDelimiter()) {
+			value = redactedValue
+		}
+		snapshot.Values[key] = SnapshotValue{Value: value, Source: result.Provenance[key].String()}
+	}
+	return snapshot
+}
+
+// hashSettings returns SHA-256 of canonical JSON of settings (encoding/json sorts map keys)
+func hashSettings(settings map[string]interface{}) string {
+	data, err := json.Marshal(settings)
+	if err != nil {
+		data = []byte(fmt.Sprint(settings)) // Normalized values are always serializable, just in case
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeValue converts a config value into a canonical form: scalars become strings, maps get string keys
+func normalizeValue(value interface{}) interface{} {
+	if m, ok := toStringMap(value); ok {
+		ret := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			ret[k] = normalizeValue(v)
+		}
+		return ret
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8 {
+		ret := make([]interface{}, rv.Len())
+		for idx := range ret {
+			ret[idx] = normalizeValue(rv.Index(idx).Interface())
+		}
+		return ret
+	}
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case fmt.Stringer:
+		return v.String()
+	case float32, float64:
+		return strconv.FormatFloat(cast.ToFloat64(v), 'g', -1, 64)
+	}
+	if str, err := cast.ToStringE(value); err == nil {
+		return str
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,89 @@
+package xcommon
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// configStructField is a field of a config structure with its dotted config key
+type configStructField struct {
+	Key   string              // Dotted config key as viper sees it (lowercased)
+	Field reflect.StructField // Struct field description
+	Index []int               // Field index sequence from the root structure
+	Root  interface{}         // Root structure (cfgStruct or a config section) the field belongs to
+}
+
+// walkConfigStruct calls fn for every exported field of a config structure (including nested structures).
+// Keys are built the same way mapstructure does: `mapstructure` tag name or a field name
+// If fn returns false nested structure of this field is not walked. Config sections are walked with their paths as key prefixes.
+// Self-referential structures (such as `Else *Rule` in Rule) are walked only once on every path
+func walkConfigStruct(cfgStruct interface{}, delimiter string, fn func(field configStructField) bool) {
+	for _, root := range configStructRoots(cfgStruct) {
+		_walkConfigStruct(reflect.TypeOf(root.Struct), root.Prefix, nil, root.Struct, nil, delimiter, fn)
+	}
+}
+
+func _walkConfigStruct(
+	sType reflect.Type,
+	prefix string,
+	index []int,
+	root interface{},
+	visiting []reflect.Type, // Structure types on the current path
+	delimiter string,
+	fn func(field configStructField) bool,
+) {
+	t := derefType(sType)
+	if t.Kind() != reflect.Struct || slices.Contains(visiting, t) {
+		return
+	}
+	visiting = append(slices.Clip(visiting), t)
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, squash := configFieldName(f)
+		if name == "-" {
+			continue
+		}
+		fieldIndex := append(append([]int{}, index...), i)
+		if squash {
+			_walkConfigStruct(f.Type, prefix, fieldIndex, root, visiting, delimiter, fn)
+			continue
+		}
+		key := joinKey(prefix, name, delimiter)
+		if !fn(configStructField{Key: key, Field: f, Index: fieldIndex, Root: root}) {
+			continue
+		}
+		if derefType(f.Type).Kind() == reflect.Struct {
+			_walkConfigStruct(f.Type, key, fieldIndex, root, visiting, delimiter, fn)
+		}
+	}
+}
+
+// configFieldName returns lowercased config name of the field and whether it should be squashed into the parent
+func configFieldName(f reflect.StructField) (string, bool) {
+	name := f.Name
+	squash := false
+	if tag, ok := f.Tag.Lookup("mapstructure"); ok {
+		parts := strings.Split(tag, ",")
+		if parts[0] != "" {
+			name = parts[0]
+		}
+		for _, opt := range parts[1:] {
+			if opt == "squash" {
+				squash = true
+			}
+		}
+	}
+	return strings.ToLower(name), squash
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
@@ -0,0 +1,109 @@
+package xcommon
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const (
+	templateExt             = ".tmpl"
+	maxTemplateIncludeDepth = 10
+)
+
+var templateErrorRe = regexp.MustCompile(`^template: .*?:(\d+)(?::(\d+))?: `)
+
+// isTemplateFile checks if config file should be rendered as a template before parsing
+func isTemplateFile(viperConfig *ViperConfig, cfgPath string) bool {
+	return viperConfig.RenderTemplates || strings.HasSuffix(cfgPath, templateExt)
+}
+
+// renderConfigTemplate renders config file contents with text/template and a safe function set:
+//
+//	env "NAME"            - value of environment variable
+//	default "def" value   - def if value is empty
+//	hostname              - host name of the machine
+//	file "path"           - contents of a file (relative to the template file)
+//	include "path" data   - rendered contents of another template (relative to the template file)
+//	atoi "10"             - converts string into integer (e.g. for {{ range atoi (env "WORKERS") }})
+func renderConfigTemplate(cfgPath string, data []byte) ([]byte, error) {
+	return renderTemplateDepth(cfgPath, data, nil, 0)
+}
+
+func renderTemplateDepth(cfgPath string, data []byte, dot interface{}, depth int) ([]byte, error) {
+	if depth > maxTemplateIncludeDepth {
+		return nil, fmt.Errorf("template '%s': too deep includes", cfgPath)
+	}
+	baseDir := filepath.Dir(cfgPath)
+	resolve := func(path string) string {
+		path = expandPath(path)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+		return path
+	}
+
+	funcs := template.FuncMap{
+		"env": os.Getenv,
+		"default": func(def interface{}, value interface{}) interface{} {
+			if value == nil || fmt.Sprint(value) == "" {
+				return def
+			}
+			return value
+		},
+		"hostname": os.Hostname,
+		"file": func(path string) (string, error) {
+			contents, err := os.ReadFile(resolve(path))
+			return string(contents), err
+		},
+		"include": func(path string, includeDot ...interface{}) (string, error) {
+			includePath := resolve(path)
+			contents, err := os.ReadFile(includePath)
+			if err != nil {
+				return "", err
+			}
+			var nextDot interface{}
+			if len(includeDot) > 0 {
+				nextDot = includeDot[0]
+			}
+			rendered, err := renderTemplateDepth(includePath, contents, nextDot, depth+1)
+			return string(rendered), err
+		},
+		"atoi": strconv.Atoi,
+	}
+
+	tmpl, err := template.New(filepath.Base(cfgPath)).Funcs(funcs).Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return nil, newTemplateError(cfgPath, data, err)
+	}
+	out := &bytes.Buffer{}
+	if err := tmpl.Execute(out, dot); err != nil {
+		return nil, newTemplateError(cfgPath, data, err)
+	}
+	return out.Bytes(), nil
+}
+
+// newTemplateError maps text/template error to the template source line
+func newTemplateError(cfgPath string, data []byte, err error) error {
+	var includeErr *ConfigParseError
+	if errors.As(err, &includeErr) {
+		return includeErr // Already mapped error from an included template
+	}
+	parseErr := &ConfigParseError{Path: cfgPath, Err: err}
+	if match := templateErrorRe.FindStringSubmatch(err.Error()); match != nil {
+		parseErr.Line, _ = strconv.Atoi(match[1])
+		if match[2] != "" {
+			column, _ := strconv.Atoi(match[2])
+			parseErr.Column = column + 1 // text/template columns are 0-based
+		}
+		parseErr.Err = fmt.Errorf("%s", strings.TrimPrefix(err.Error(), match[0]))
+	}
+	parseErr.Excerpt = sourceExcerpt(data, parseErr.Line, parseErr.Column)
+	return parseErr
+}
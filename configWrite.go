@@ -0,0 +1,666 @@
+package xcommon
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+)
+
+// encodeSettings serializes nested settings map in a given config format
+func encodeSettings(format string, settings map[string]interface{}, delimiter string) ([]byte, error) {
+	memFs := afero.NewMemMapFs()
+	encoder := newViper(delimiter)
+	encoder.SetFs(memFs)
+	if err := encoder.MergeConfigMap(copySettings(settings)); err != nil {
+		return nil, err
+	}
+	if format == "jsonc" || format == "json5" {
+		format = "json" // Plain JSON is valid JSONC and JSON5
+	}
+	outPath := "/config." + format
+	if err := encoder.WriteConfigAs(outPath); err != nil {
+		return nil, err
+	}
+	return afero.ReadFile(memFs, outPath)
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory and renames it over path.
+// File mode of an existing file is preserved
+func writeFileAtomic(path string, data []byte) error {
+	mode := os.FileMode(0644)
+	if stat, err := os.Stat(path); err == nil {
+		mode = stat.Mode().Perm()
+	}
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmpFile.Name()
+	defer os.Remove(tmpPath) // No-op after successful rename
+
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// SetConfigValues updates dotted keys in a config file and atomically rewrites it.
+// Comments, key order and untouched sections are preserved. A nil value removes the key.
+// filePath must be one of ParsedConfigs or ViperConfig.UserConfigFile. Empty filePath means UserConfigFile.
+// The file is created if it doesn't exist. YAML, TOML, JSON, JSONC and JSON5 files are supported.
+// Standard input, inline configs and templates can't be updated.
+// Loaded configuration is not changed, new values are used on the next configuration load
+func (result *ConfigurationResult) SetConfigValues(filePath string, values map[string]interface{}) error {
+	rules := &result.ConfigurePlan.ConfigParsingRules
+	userConfigFile := expandPath(rules.UserConfigFile)
+	if filePath == "" {
+		if userConfigFile == "" {
+			return fmt.Errorf("user config file is not configured")
+		}
+		filePath = userConfigFile
+	}
+	if filePath != userConfigFile && !slices.Contains(result.ParsedConfigs, filePath) {
+		if filePath != "" && filePath == result.ConfigurePlan.InlineConfigEnv {
+			return fmt.Errorf("inline config in %s can't be updated", filePath)
+		}
+		return fmt.Errorf("config file '%s' is not loaded", filePath)
+	}
+	path, format := splitFormatPrefix(filePath)
+	if path == stdinConfigPath {
+		return fmt.Errorf("config read from standard input can't be updated")
+	}
+	if isTemplateFile(rules, path) {
+		return fmt.Errorf("config file '%s' is a template and can't be updated", filePath)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if format == "" {
+		format = configFileFormat(path, data)
+	}
+	updated, err := setFileValues(path, format, data, values, rules.keyDelimiter())
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, updated)
+}
+
+// setFileValues sets dotted keys in contents of a config file of a given format keeping its formatting and comments.
+// YAML, TOML, JSON, JSONC and JSON5 are supported
+func setFileValues(filePath string, format string, data []byte, values map[string]interface{}, delimiter string) ([]byte, error) {
+	var updated []byte
+	var err error
+	switch format = strings.ToLower(format); format {
+	case "yaml", "yml":
+		updated, err = setYAMLValues(data, values, delimiter)
+	case "toml":
+		updated, err = setTOMLValues(data, values, delimiter)
+	case "json", "jsonc", "json5":
+		updated, err = setJSONValues(data, format, values, delimiter)
+	default:
+		return nil, fmt.Errorf("config file '%s': writing of '%s' format is not supported", filePath, format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file '%s': %w", filePath, err)
+	}
+	return updated, nil
+}
+
+// sortedKeys returns keys of values in a stable order
+func sortedKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// setYAMLValues updates keys in a YAML document node tree.
+// Only the first document of a multi-document stream is loaded as config, so the others are written back unchanged
+func setYAMLValues(data []byte, values map[string]interface{}, delimiter string) ([]byte, error) {
+	var docs []*yaml.Node
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		doc := &yaml.Node{}
+		if err := decoder.Decode(doc); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	if len(docs) == 0 || docs[0].Kind == 0 {
+		docs = []*yaml.Node{{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}}
+	}
+	root := docs[0].Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("root of the document is not a mapping")
+	}
+
+	for _, key := range sortedKeys(values) {
+		if err := setYAMLValue(root, splitKey(key, delimiter), values[key]); err != nil {
+			return nil, fmt.Errorf("key '%s': %w", key, err)
+		}
+	}
+
+	out := &bytes.Buffer{}
+	encoder := yaml.NewEncoder(out)
+	encoder.SetIndent(detectIndent(data))
+	for _, doc := range docs {
+		if err := encoder.Encode(doc); err != nil {
+			return nil, err
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func setYAMLValue(mapping *yaml.Node, path []string, value interface{}) error {
+	idx := -1
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if strings.ToLower(mapping.Content[i].Value) == path[0] {
+			idx = i
+			break
+		}
+	}
+
+	if len(path) > 1 {
+		if idx < 0 {
+			if value == nil {
+				return nil
+			}
+			mapping.Content = append(mapping.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: path[0]},
+				&yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"},
+			)
+			idx = len(mapping.Content) - 2
+		}
+		child := mapping.Content[idx+1]
+		if child.Kind != yaml.MappingNode {
+			return fmt.Errorf("'%s' is not a mapping", path[0])
+		}
+		return setYAMLValue(child, path[1:], value)
+	}
+
+	if value == nil {
+		if idx >= 0 {
+			mapping.Content = append(mapping.Content[:idx], mapping.Content[idx+2:]...)
+		}
+		return nil
+	}
+	valueNode := &yaml.Node{}
+	if err := valueNode.Encode(value); err != nil {
+		return err
+	}
+	if idx < 0 {
+		mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: path[0]}, valueNode)
+		return nil
+	}
+	oldNode := mapping.Content[idx+1]
+	valueNode.HeadComment = oldNode.HeadComment
+	valueNode.LineComment = oldNode.LineComment
+	valueNode.FootComment = oldNode.FootComment
+	if valueNode.Kind == yaml.ScalarNode && oldNode.Kind == yaml.ScalarNode && valueNode.Tag == "!!str" {
+		valueNode.Style = oldNode.Style // Keep quoting style of strings
+	}
+	mapping.Content[idx+1] = valueNode
+	return nil
+}
+
+// detectIndent returns indentation width of the first indented line or 2
+func detectIndent(data []byte) int {
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if indent := len(line) - len(trimmed); indent > 0 && trimmed != "" && !strings.HasPrefix(trimmed, "#") && !strings.HasPrefix(trimmed, "- ") {
+			return indent
+		}
+	}
+	return 2
+}
+
+var tomlTableRe = regexp.MustCompile(`^\s*\[\s*([^\[\]]+?)\s*\]\s*(#.*)?$`)
+var tomlArrayTableRe = regexp.MustCompile(`^\s*\[\[`)
+
+// setTOMLValues updates keys in TOML document line by line keeping everything else untouched
+func setTOMLValues(data []byte, values map[string]interface{}, delimiter string) ([]byte, error) {
+	lines := splitLines(string(data))
+	flat := map[string]interface{}{}
+	flattenValues(values, "", flat, delimiter)
+
+	for _, key := range sortedKeys(flat) {
+		var err error
+		lines, err = setTOMLValue(lines, key, flat[key], delimiter)
+		if err != nil {
+			return nil, fmt.Errorf("key '%s': %w", key, err)
+		}
+	}
+	updated := []byte(strings.Join(lines, "\n") + "\n")
+	if err := checkTOMLValues(data, updated, flat, delimiter); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// checkTOMLValues verifies that the updated document differs from the original one only by the requested values
+func checkTOMLValues(data []byte, updated []byte, flat map[string]interface{}, delimiter string) error {
+	original := map[string]interface{}{}
+	if err := toml.Unmarshal(data, &original); err != nil {
+		return err
+	}
+	expected := lowerKeys(original).(map[string]interface{})
+	for _, key := range sortedKeys(flat) {
+		if flat[key] == nil {
+			deleteKey(expected, key, delimiter)
+		} else {
+			setKey(expected, key, flat[key], delimiter)
+		}
+	}
+	// Round trip expected values to get the same types as decoded from the updated document
+	encoded, err := toml.Marshal(expected)
+	if err != nil {
+		return err
+	}
+	expected = map[string]interface{}{}
+	if err := toml.Unmarshal(encoded, &expected); err != nil {
+		return err
+	}
+
+	actual := map[string]interface{}{}
+	if err := toml.Unmarshal(updated, &actual); err != nil {
+		return fmt.Errorf("updated document is not valid TOML: %w", err)
+	}
+	if !reflect.DeepEqual(lowerKeys(actual), lowerKeys(expected)) {
+		return fmt.Errorf("document can't be updated without changing other values")
+	}
+	return nil
+}
+
+// lowerKeys returns a copy of a decoded value with all map keys lowercased
+func lowerKeys(value interface{}) interface{} {
+	if m, ok := toStringMap(value); ok {
+		ret := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			ret[strings.ToLower(k)] = lowerKeys(v)
+		}
+		return ret
+	}
+	if list, ok := value.([]interface{}); ok {
+		ret := make([]interface{}, len(list))
+		for idx, item := range list {
+			ret[idx] = lowerKeys(item)
+		}
+		return ret
+	}
+	return value
+}
+
+// flattenValues converts nested maps in values into dotted leaf keys
+func flattenValues(values map[string]interface{}, prefix string, out map[string]interface{}, delimiter string) {
+	for key, value := range values {
+		fullKey := joinKey(prefix, strings.ToLower(key), delimiter)
+		if nested, ok := toStringMap(value); ok {
+			flattenValues(nested, fullKey, out, delimiter)
+			continue
+		}
+		out[fullKey] = value
+	}
+}
+
+func setTOMLValue(lines []string, key string, value interface{}, delimiter string) ([]string, error) {
+	path := splitKey(key, delimiter)
+	table, name := path[:len(path)-1], path[len(path)-1]
+
+	// Find the table section and the key line in it. Keys may be dotted (a.b = 1 defines table a implicitly)
+	var currentTable []string
+	inArrayTable := false
+	sectionFound := len(table) == 0
+	sectionEnd := len(lines) // Line to insert a new key into the section
+	keyLine := -1
+	inlineLine := -1         // Line with an inline table containing the key
+	var inlineKey []string   // Full key of the inline table
+	dottedLine := -1         // Last line with a dotted key defining the table implicitly
+	var dottedTable []string // Table of dottedLine
+	continued := tomlContinuationLines(lines)
+	for idx, line := range lines {
+		if continued[idx] {
+			continue // Lines of multi-line strings and arrays
+		}
+		if tomlArrayTableRe.MatchString(line) {
+			if slices.Equal(currentTable, table) && sectionFound && !inArrayTable {
+				sectionEnd = min(sectionEnd, idx)
+			}
+			inArrayTable = true // Arrays of tables are never edited
+			continue
+		}
+		if match := tomlTableRe.FindStringSubmatch(line); match != nil {
+			if slices.Equal(currentTable, table) && sectionFound && !inArrayTable {
+				sectionEnd = min(sectionEnd, idx)
+			}
+			currentTable, inArrayTable = parseTOMLKey(match[1]), false
+			if slices.Equal(currentTable, table) {
+				sectionFound = true
+				sectionEnd = len(lines)
+			}
+			continue
+		}
+		lineKey, rawValue, ok := strings.Cut(line, "=")
+		if inArrayTable || !ok || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		fullKey := append(slices.Clip(currentTable), parseTOMLKey(lineKey)...)
+		switch {
+		case slices.Equal(fullKey, path):
+			if keyLine < 0 {
+				keyLine = idx
+			}
+		case len(fullKey) < len(path) && slices.Equal(fullKey, path[:len(fullKey)]):
+			if !strings.HasPrefix(strings.TrimSpace(rawValue), "{") {
+				return nil, fmt.Errorf("'%s' is not a table", strings.Join(fullKey, "."))
+			}
+			inlineLine, inlineKey = idx, fullKey
+		case len(fullKey) > len(table) && len(currentTable) < len(table) && slices.Equal(fullKey[:len(table)], table):
+			dottedLine, dottedTable = idx, currentTable
+		}
+	}
+
+	if inlineLine >= 0 {
+		return setTOMLInlineValue(lines, inlineLine, path[len(inlineKey):], value, delimiter)
+	}
+	if keyLine >= 0 {
+		valueEnd := tomlValueEnd(continued, keyLine)
+		if value == nil {
+			return slices.Delete(lines, keyLine, valueEnd), nil
+		}
+		encoded, err := encodeTOMLValue(value)
+		if err != nil {
+			return nil, err
+		}
+		lineKey, rawValue, _ := strings.Cut(lines[keyLine], "=")
+		comment := ""
+		if idx := tomlCommentIndex(rawValue); idx >= 0 && valueEnd == keyLine+1 {
+			comment = " " + rawValue[idx:]
+		}
+		// A multi-line value is replaced by a single line
+		return slices.Replace(lines, keyLine, valueEnd, strings.TrimRight(lineKey, " ")+" = "+encoded+comment), nil
+	}
+	if value == nil {
+		return deleteTOMLTable(lines, path), nil
+	}
+
+	encoded, err := encodeTOMLValue(value)
+	if err != nil {
+		return nil, err
+	}
+	newLine := formatTOMLKey([]string{name}) + " = " + encoded
+	if !sectionFound {
+		if dottedLine >= 0 {
+			// The table is defined by dotted keys, a [table] header would redefine it
+			line := lines[dottedLine]
+			indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+			return slices.Insert(lines, tomlValueEnd(continued, dottedLine), indent+formatTOMLKey(path[len(dottedTable):])+" = "+encoded), nil
+		}
+		if len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) != "" {
+			lines = append(lines, "")
+		}
+		return append(lines, "["+formatTOMLKey(table)+"]", newLine), nil
+	}
+	// Insert after the last non-empty line of the section
+	insertAt := sectionEnd
+	if len(table) == 0 {
+		// Root keys must be placed before the first table
+		for idx, line := range lines {
+			if !continued[idx] && (tomlTableRe.MatchString(line) || tomlArrayTableRe.MatchString(line)) {
+				insertAt = idx
+				break
+			}
+		}
+	}
+	for insertAt > 0 && strings.TrimSpace(lines[insertAt-1]) == "" && !continued[insertAt-1] {
+		insertAt--
+	}
+	return slices.Insert(lines, insertAt, newLine), nil
+}
+
+// setTOMLInlineValue sets a key relative to the inline table on the line by rewriting the whole inline table
+func setTOMLInlineValue(lines []string, line int, path []string, value interface{}, delimiter string) ([]string, error) {
+	lineKey, rawValue, _ := strings.Cut(lines[line], "=")
+	if !isCompleteTOMLValue(rawValue) {
+		return nil, fmt.Errorf("multi-line values can't be updated")
+	}
+	comment := ""
+	if idx := tomlCommentIndex(rawValue); idx >= 0 {
+		rawValue, comment = rawValue[:idx], " "+rawValue[idx:]
+	}
+	parsed := map[string]interface{}{}
+	if err := toml.Unmarshal([]byte("v = "+rawValue), &parsed); err != nil {
+		return nil, err
+	}
+	table, _ := toStringMap(parsed["v"])
+	if value == nil {
+		deleteKey(table, strings.Join(path, delimiter), delimiter)
+	} else {
+		setKey(table, strings.Join(path, delimiter), value, delimiter)
+	}
+	encoded, err := encodeTOMLValue(table)
+	if err != nil {
+		return nil, err
+	}
+	lines[line] = strings.TrimRight(lineKey, " ") + " = " + encoded + comment
+	return lines, nil
+}
+
+var tomlArrayTableHeaderRe = regexp.MustCompile(`^\s*\[\[\s*([^\[\]]+?)\s*\]\]\s*(#.*)?$`)
+
+// deleteTOMLTable removes a table with its subtables and dotted keys defining it
+func deleteTOMLTable(lines []string, path []string) []string {
+	var ret []string
+	var currentTable []string
+	inArrayTable := false
+	removed := false // Lines of a multi-line value follow its key line
+	continued := tomlContinuationLines(lines)
+	for idx, line := range lines {
+		if !continued[idx] {
+			removed = false
+			if match := tomlArrayTableHeaderRe.FindStringSubmatch(line); match != nil {
+				currentTable, inArrayTable = parseTOMLKey(match[1]), true
+			} else if match := tomlTableRe.FindStringSubmatch(line); match != nil {
+				currentTable, inArrayTable = parseTOMLKey(match[1]), false
+			} else if lineKey, _, ok := strings.Cut(line, "="); ok && !inArrayTable && !strings.HasPrefix(strings.TrimSpace(line), "#") {
+				removed = hasKeyPrefix(append(slices.Clip(currentTable), parseTOMLKey(lineKey)...), path)
+			}
+		}
+		if !removed && !hasKeyPrefix(currentTable, path) {
+			ret = append(ret, line)
+		}
+	}
+	return ret
+}
+
+// hasKeyPrefix checks if key parts start with prefix parts
+func hasKeyPrefix(key []string, prefix []string) bool {
+	return len(key) >= len(prefix) && slices.Equal(key[:len(prefix)], prefix)
+}
+
+var tomlBareKeyRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// parseTOMLKey splits a raw TOML key or table name (such as `a."b.c".d`) into lowercased parts
+func parseTOMLKey(raw string) []string {
+	var parts []string
+	var part strings.Builder
+	var quote byte
+	for idx := 0; idx < len(raw); idx++ {
+		c := raw[idx]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' && idx+1 < len(raw) {
+				idx++
+				part.WriteByte(raw[idx])
+			} else if c == quote {
+				quote = 0
+			} else {
+				part.WriteByte(c)
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '.':
+			parts = append(parts, strings.ToLower(strings.TrimSpace(part.String())))
+			part.Reset()
+		case c != ' ' && c != '\t':
+			part.WriteByte(c)
+		}
+	}
+	return append(parts, strings.ToLower(strings.TrimSpace(part.String())))
+}
+
+// formatTOMLKey joins key parts into a dotted TOML key quoting parts that are not bare keys
+func formatTOMLKey(parts []string) string {
+	quoted := make([]string, len(parts))
+	for idx, part := range parts {
+		quoted[idx] = part
+		if !tomlBareKeyRe.MatchString(part) {
+			quoted[idx] = strconv.Quote(part)
+		}
+	}
+	return strings.Join(quoted, ".")
+}
+
+func encodeTOMLValue(value interface{}) (string, error) {
+	encoded := &bytes.Buffer{}
+	if err := toml.NewEncoder(encoded).SetTablesInline(true).Encode(map[string]interface{}{"v": value}); err != nil {
+		return "", err
+	}
+	_, ret, ok := strings.Cut(strings.TrimSpace(encoded.String()), "v = ")
+	if !ok || strings.Contains(ret, "\n") {
+		return "", fmt.Errorf("value can't be written as a single line")
+	}
+	return ret, nil
+}
+
+// tomlCommentIndex returns position of an inline comment in raw TOML value or -1
+func tomlCommentIndex(rawValue string) int {
+	var quote byte
+	for idx := 0; idx < len(rawValue); idx++ {
+		c := rawValue[idx]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				idx++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return idx
+		}
+	}
+	return -1
+}
+
+// tomlContinuationLines marks lines continuing a multi-line string, array or inline table started on a previous line
+func tomlContinuationLines(lines []string) []bool {
+	ret := make([]bool, len(lines))
+	mlQuote := "" // Delimiter of an open multi-line string
+	depth := 0    // Nesting of open arrays and inline tables
+	for idx, line := range lines {
+		ret[idx] = mlQuote != "" || depth > 0
+		var quote byte
+	scan:
+		for pos := 0; pos < len(line); pos++ {
+			c := line[pos]
+			switch {
+			case mlQuote != "":
+				if c == '\\' && mlQuote == `"""` {
+					pos++
+				} else if strings.HasPrefix(line[pos:], mlQuote) {
+					pos += 2
+					for pos+1 < len(line) && line[pos+1] == mlQuote[0] {
+						pos++ // Up to two quotes before the delimiter belong to the string
+					}
+					mlQuote = ""
+				}
+			case quote != 0:
+				if c == '\\' && quote == '"' {
+					pos++
+				} else if c == quote {
+					quote = 0
+				}
+			case strings.HasPrefix(line[pos:], `"""`) || strings.HasPrefix(line[pos:], "'''"):
+				mlQuote = line[pos : pos+3]
+				pos += 2
+			case c == '"' || c == '\'':
+				quote = c
+			case c == '#':
+				break scan
+			case c == '[' || c == '{':
+				depth++
+			case c == ']' || c == '}':
+				depth = max(depth-1, 0)
+			}
+		}
+	}
+	return ret
+}
+
+// tomlValueEnd returns the line after the last line of a value starting on the line
+func tomlValueEnd(continued []bool, line int) int {
+	end := line + 1
+	for end < len(continued) && continued[end] {
+		end++
+	}
+	return end
+}
+
+// isCompleteTOMLValue checks that raw TOML value is not continued on the next lines
+func isCompleteTOMLValue(rawValue string) bool {
+	value := strings.TrimSpace(rawValue)
+	if idx := tomlCommentIndex(value); idx >= 0 {
+		value = strings.TrimSpace(value[:idx])
+	}
+	if strings.HasPrefix(value, `"""`) || strings.HasPrefix(value, "'''") {
+		return len(value) >= 6 && (strings.HasSuffix(value, `"""`) || strings.HasSuffix(value, "'''"))
+	}
+	depth := 0
+	for _, c := range value {
+		switch c {
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+		}
+	}
+	return depth == 0
+}
@@ -0,0 +1,185 @@
+package xcommon
+
+import (
+	"testing"
+)
+
+func TestSetTOMLValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     string
+		values map[string]interface{}
+		want   string
+	}{
+		{
+			"replace value keeping comments",
+			"# app\nname = \"a\" # name\n\n[server]\nport = 1\n",
+			map[string]interface{}{"server.port": 8080},
+			"# app\nname = \"a\" # name\n\n[server]\nport = 8080\n",
+		},
+		{
+			"keys are case-insensitive",
+			"[Server]\nPort = 1\n",
+			map[string]interface{}{"server.port": 2},
+			"[Server]\nPort = 2\n",
+		},
+		{
+			"add root key before the first table",
+			"a = 1\n\n[server]\nport = 1\n",
+			map[string]interface{}{"b": true},
+			"a = 1\nb = true\n\n[server]\nport = 1\n",
+		},
+		{
+			"add table",
+			"a = 1\n",
+			map[string]interface{}{"server.port": 1},
+			"a = 1\n\n[server]\nport = 1\n",
+		},
+		{
+			"keys inside a multi-line basic string are not keys",
+			"s = \"\"\"\nfoo = bar\n[foo]\n\"\"\"\nfoo = 1\n",
+			map[string]interface{}{"foo": 2},
+			"s = \"\"\"\nfoo = bar\n[foo]\n\"\"\"\nfoo = 2\n",
+		},
+		{
+			"keys inside a multi-line literal string are not keys",
+			"s = '''\nfoo = bar\n'''\n",
+			map[string]interface{}{"foo": 2},
+			"s = '''\nfoo = bar\n'''\nfoo = 2\n",
+		},
+		{
+			"replace multi-line string",
+			"s = \"\"\"\nfoo = bar\n\"\"\" # comment\nfoo = 1\n",
+			map[string]interface{}{"s": "x"},
+			"s = 'x'\nfoo = 1\n",
+		},
+		{
+			"replace multi-line array",
+			"list = [\n  1,\n  2, # two\n]\nfoo = 1\n",
+			map[string]interface{}{"list": []interface{}{3}},
+			"list = [3]\nfoo = 1\n",
+		},
+		{
+			"remove multi-line array",
+			"list = [\n  \"a = b\",\n]\nfoo = 1\n",
+			map[string]interface{}{"list": nil},
+			"foo = 1\n",
+		},
+		{
+			"add key after a multi-line array at the end of a table",
+			"[a]\nlist = [\n  1,\n\n]\n\n[b]\n",
+			map[string]interface{}{"a.x": 1},
+			"[a]\nlist = [\n  1,\n\n]\nx = 1\n\n[b]\n",
+		},
+		{
+			"update inline table",
+			"server = { host = \"h\", port = 1 } # server\n",
+			map[string]interface{}{"server.port": 2},
+			"server = {host = 'h', port = 2} # server\n",
+		},
+		{
+			"add key to a table defined by dotted keys",
+			"server.host = \"h\"\nname = \"a\"\n",
+			map[string]interface{}{"server.port": 1},
+			"server.host = \"h\"\nserver.port = 1\nname = \"a\"\n",
+		},
+		{
+			"arrays of tables are kept",
+			"[[items]]\nname = \"a\"\n\n[server]\nport = 1\n",
+			map[string]interface{}{"server.port": 2},
+			"[[items]]\nname = \"a\"\n\n[server]\nport = 2\n",
+		},
+		{
+			"remove table with subtables",
+			"a = 1\n\n[server]\nport = 1\n\n[server.tls]\ncert = \"\"\"\nx\n\"\"\"\n",
+			map[string]interface{}{"server": nil},
+			"a = 1\n\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := setTOMLValues([]byte(test.in), test.values, ".")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(got) != test.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSetTOMLValuesErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     string
+		values map[string]interface{}
+	}{
+		{"parent is not a table", "a = 1\n", map[string]interface{}{"a.b": 1}},
+		{"key of an array of tables", "[[items]]\nname = \"a\"\n", map[string]interface{}{"items.name": "b"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := setTOMLValues([]byte(test.in), test.values, "."); err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
+
+func TestSetYAMLValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     string
+		values map[string]interface{}
+		want   string
+	}{
+		{
+			"replace value keeping comments",
+			"# app\nserver:\n    # port\n    port: 1 # port\n",
+			map[string]interface{}{"server.port": 8080},
+			"# app\nserver:\n    # port\n    port: 8080 # port\n",
+		},
+		{
+			"keep quoting style",
+			"name: 'a'\n",
+			map[string]interface{}{"name": "b"},
+			"name: 'b'\n",
+		},
+		{
+			"add nested key",
+			"a: 1\n",
+			map[string]interface{}{"b.c": true},
+			"a: 1\nb:\n  c: true\n",
+		},
+		{
+			"remove key",
+			"a: 1\nb: 2\n",
+			map[string]interface{}{"a": nil},
+			"b: 2\n",
+		},
+		{
+			"create document",
+			"",
+			map[string]interface{}{"a": 1},
+			"a: 1\n",
+		},
+		{
+			"later documents are kept",
+			"a: 1\n---\na: 2\n---\nb: 3\n",
+			map[string]interface{}{"a": 5},
+			"a: 5\n---\na: 2\n---\nb: 3\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := setYAMLValues([]byte(test.in), test.values, ".")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(got) != test.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, test.want)
+			}
+		})
+	}
+}
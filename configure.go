@@ -1,8 +1,10 @@
 package xcommon
 
 import (
-	"strings"
+	"os"
+	"reflect"
 
+	"github.com/mitchellh/mapstructure"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -13,19 +15,56 @@ import (
 // It envolves config parsing, binding commandline, environment and config parameters together
 // And it contains all your defaults for Configure function to work
 type ConfigurePlan struct {
-	ConfigParsingRules    ViperConfig // Parsing rules for configs
-	DontBindFlagsToConfig bool        // Do not bind pflags to Viper config
-	DontBindEnvToConfig   bool        // Do not bind environment variables to Viper config
-	EnvVariablesPrefix    string      // Look up only prefixed ENV variables
-	ConfigOverrideFlag    string      // Flag that should override normal configuration file searching. Such as "--config" (without dashes). These files will be used for config reading
+	ConfigParsingRules    ViperConfig                   // Parsing rules for configs
+	DontBindFlagsToConfig bool                          // Do not bind pflags to Viper config
+	DontBindEnvToConfig   bool                          // Do not bind environment variables to Viper config
+	EnvVariablesPrefix    string                        // Look up only prefixed ENV variables
+	ConfigOverrideFlag    string                        // Flag that should override normal configuration file searching. Such as "--config" (without dashes). These files will be used for config reading
+	DeprecatedKeys        []DeprecatedKey               // Renamed config keys. Old keys are migrated to new ones with a warning. `deprecated:"old.key"` tags of cfgStruct are added here
+	AppVersion            string                        // Version of the application. Used to decide if deprecated keys are already fatal
+	ConfigVersionKey      string                        // Config key with config schema version. Such as "config_version". Empty disables migrations. The primary config file without it is version 0, other files and inline config are the latest version
+	ConfigMigrations      []ConfigMigration             // ConfigMigrations[N] upgrades config from version N to N+1. Latest version is len(ConfigMigrations)
+	MergeStrategies       map[string]MergeStrategy      // Merge strategies of dotted config keys applied across all config sources. `merge:"append"` tags of cfgStruct are added here
+	SetFlag               string                        // Repeatable flag that overrides a single config value: --set a.b.c=value. Such as "set" (without dashes). Highest precedence
+	SetFileFlag           string                        // Repeatable flag that overrides a single config value with file contents: --set-file key=path. Such as "set-file" (without dashes)
+	DecodeHooks           []mapstructure.DecodeHookFunc // Additional decode hooks for unmarshalling into cfgStruct. Applied before StandardDecodeHooks()
+	SecretKeys            []string                      // Dotted config keys with secret values (including all nested keys). `secret:"true"` tags of cfgStruct are added here. Values like "file:/run/secrets/db" in config files are read from the file
+	InlineConfigEnv       string                        // Environment variable with a whole config document (such as "APP_CONFIG_YAML"). Format is detected by contents, "base64:" prefix is decoded. Merged after config files
+	ConfigPathsEnv        string                        // Environment variable with config file paths separated by os.PathListSeparator (such as APP_CONFIG=/a.yaml:/b.yaml). Used when ConfigOverrideFlag is absent
+	RequiredKeys          []string                      // Dotted config keys that must be set by any source (file, env, flag or --set). `required:"true"` tags of cfgStruct are added here
+	Sections              []*ConfigSection              // Config sections of reusable components. Composed into the config tree and unmarshalled into their own structures. Paths must not overlap
+	CommandSections       map[string]*ConfigSection     // Config sections of subcommands by command path without the root command (such as "serve" or "db migrate"). Decoded and validated only when the subcommand is invoked
+	SourcePrecedence      []ConfigLayer                 // Layers of config sources from the lowest to the highest precedence. Empty means viper order (see DefaultSourcePrecedence). Unlisted layers are not used. The merged result is only in ConfigurationResult.Viper, global viper is not used
+	DotenvFile            string                        // Dotenv file with environment variables for LayerDotenv
+	CustomSources         map[string]ConfigSource       // Custom config sources by layer names. Every source must be listed in SourcePrecedence
 }
 
+// ConfigPathsSource describes how the list of config files was chosen
+type ConfigPathsSource string
+
+const (
+	ConfigPathsFromFlag   ConfigPathsSource = "flag"   // Files from ConfigOverrideFlag
+	ConfigPathsFromEnv    ConfigPathsSource = "env"    // Files from ConfigPathsEnv environment variable
+	ConfigPathsFromPlan   ConfigPathsSource = "plan"   // ViperConfig.ConcreeteFilePaths of the configuration plan
+	ConfigPathsFromSearch ConfigPathsSource = "search" // Automatic search of ViperConfig.SearchFiles
+)
+
 // ConfigurationResult stores a result of Configure function
 type ConfigurationResult struct {
-	ConfigurePlan *ConfigurePlan // Used configuration plan
-	ParsedConfigs []string       // A list of loaded config filepaths
-	RootCmd       *cobra.Command // A pointer to root cobra command structure
-	Viper         *viper.Viper   // Viper instance
+	ConfigurePlan *ConfigurePlan         // Used configuration plan
+	ParsedConfigs []string               // A list of loaded config filepaths
+	RootCmd       *cobra.Command         // A pointer to root cobra command structure
+	Viper         *viper.Viper           // Viper instance
+	Provenance    map[string]ValueSource // Source of every effective config key
+	ConfigPaths   ConfigPathsSource      // How the list of config files was chosen
+
+	configFiles   []*configFile          // Parsed config files
+	overrides     map[string]ValueSource // Keys overridden by --set and --set-file flags
+	defaultConfig map[string]interface{} // Default config passed to InitCobra
+	cfgStruct     interface{}            // Config structure passed to InitCobra together with config sections
+	layers        []sourceLayer          // Config sources in order of precedence
+	bindFlags     map[string]*pflag.Flag // Flags bound to config keys, including flags of config sections
+	decoded       []interface{}          // Structures decoded from the configuration: cfgStruct and sections of invoked subcommands
 }
 
 type CobraBuilderFunc func() (*cobra.Command, map[string]*pflag.Flag, error)
@@ -42,17 +81,72 @@ func InitCobra(
 	if err != nil {
 		return nil, err
 	}
+	if err := configPlan.ConfigParsingRules.checkKeyDelimiter(); err != nil {
+		return nil, err
+	}
+	delimiter := configPlan.ConfigParsingRules.keyDelimiter()
+	if err := checkConfigSections(configPlan.Sections, configPlan.CommandSections, delimiter); err != nil {
+		return nil, err
+	}
+	if err := checkSourcePrecedence(configPlan); err != nil {
+		return nil, err
+	}
+	startStruct := cfgStruct // Decoded by the initializer. Subcommand sections are decoded when the subcommand is invoked
+	if len(configPlan.Sections) > 0 || len(configPlan.CommandSections) > 0 {
+		tree := &configTree{Root: cfgStruct, Sections: configPlan.Sections, Commands: configPlan.CommandSections}
+		cfgStruct = tree
+		startStruct = tree.loadedOnStart()
+		defaultConfig = composeSectionDefaults(defaultConfig, configStructSections(tree), delimiter)
+		bindFlags = registerSectionFlags(rootCmd, bindFlags, configPlan.Sections, delimiter)
+		if bindFlags, err = registerCommandSections(rootCmd, bindFlags, configPlan.CommandSections, configurationResult, delimiter); err != nil {
+			return nil, err
+		}
+	}
 
 	if configPlan.ConfigOverrideFlag != "" {
 		rootCmd.PersistentFlags().StringArray(configPlan.ConfigOverrideFlag, nil, "override configuration files")
 	}
+	registerOverrideFlags(rootCmd, configPlan, cfgStruct)
 
 	initializer := func() {
-		result, err := configure(rootCmd, configPlan, defaultConfig, cfgStruct)
+		result, err := loadConfiguration(rootCmd, configPlan, defaultConfig, cfgStruct, bindFlags)
 		if err != nil {
 			panic(err)
 		}
 		*configurationResult = result
+		if err := checkRequiredKeys(result, bindFlags, startStruct); err != nil {
+			panic(err)
+		}
+
+		// Save configuration in struct
+		if err := decodeConfigStruct(result, startStruct); err != nil {
+			panic(err)
+		}
+		result.decoded = append(result.decoded, startStruct)
+	}
+	cobra.OnInitialize(initializer)
+	cobra.OnInitialize(initializers...)
+	return rootCmd, nil
+}
+
+// loadConfiguration loads configuration from all sources, applies source precedence and --set overrides.
+// cfgStruct is not decoded
+func loadConfiguration(
+	rootCmd *cobra.Command,
+	configPlan *ConfigurePlan,
+	defaultConfig map[string]interface{},
+	cfgStruct interface{},
+	bindFlags map[string]*pflag.Flag,
+) (*ConfigurationResult, error) {
+	result, err := configure(rootCmd, configPlan, defaultConfig, cfgStruct)
+	if err != nil {
+		return nil, err
+	}
+	if len(configPlan.SourcePrecedence) > 0 {
+		if err := applyExplicitPrecedence(rootCmd, result, bindFlags, collectSecretKeys(configPlan, cfgStruct)); err != nil {
+			return nil, err
+		}
+	} else {
 		if bindFlags != nil && !configPlan.DontBindFlagsToConfig {
 			for key, flag := range bindFlags {
 				if err := result.Viper.BindPFlag(key, flag); err != nil {
@@ -60,15 +154,93 @@ func InitCobra(
 				}
 			}
 		}
+		// Overrides may change items of merged lists, so lists are merged before and after them
+		layers, err := collectSourceLayers(result, bindFlags, nil)
+		if err != nil {
+			return nil, err
+		}
+		if err := applyMergeStrategies(result, layers); err != nil {
+			return nil, err
+		}
+		overrides, err := applyOverrideFlags(rootCmd, configPlan, result.Viper, collectSecretKeys(configPlan, cfgStruct))
+		if err != nil {
+			return nil, err
+		}
+		result.overrides = overrides
+		result.Provenance = buildProvenance(result, bindFlags)
+		overrideValues := map[string]interface{}{}
+		for key := range overrides {
+			overrideValues[key] = result.Viper.Get(key)
+		}
+		if result.layers, err = collectSourceLayers(result, bindFlags, overrideValues); err != nil {
+			return nil, err
+		}
+		if err := applyMergeStrategies(result, result.layers); err != nil {
+			return nil, err
+		}
+	}
+	result.bindFlags = bindFlags
+	return result, nil
+}
+
+// Reload loads configuration from all sources again and decodes it into cfgStruct and config sections that are already decoded.
+// Structures are reset to zero values first, then the lifecycle is the same as on initial load (see decodeConfigStruct).
+// result is replaced only if everything succeeds, but structures may be partially updated on error.
+// Global viper is reset if result uses it
+func (result *ConfigurationResult) Reload() error {
+	if result.ConfigurePlan.ConfigParsingRules.usesGlobalViper() {
+		viper.Reset() // Keys removed from config files must not survive in the global instance
+	}
+	reloaded, err := loadConfiguration(result.RootCmd, result.ConfigurePlan, result.defaultConfig, result.cfgStruct, result.bindFlags)
+	if err != nil {
+		return err
+	}
+	for _, cfgStruct := range result.decoded {
+		if err := checkRequiredKeys(reloaded, result.bindFlags, cfgStruct); err != nil {
+			return err
+		}
+		resetConfigStruct(cfgStruct)
+		if err := decodeConfigStruct(reloaded, cfgStruct); err != nil {
+			return err
+		}
+	}
+	reloaded.decoded = result.decoded
+	*result = *reloaded
+	return nil
+}
 
-		// Save configuration in struct
-		if err := result.Viper.Unmarshal(&cfgStruct); err != nil {
-			panic(err)
+// resetConfigStruct sets cfgStruct and config sections to zero values, so keys removed from the configuration don't keep old values
+func resetConfigStruct(cfgStruct interface{}) {
+	for _, root := range configStructRoots(cfgStruct) {
+		if value := reflect.ValueOf(root.Struct); value.Kind() == reflect.Pointer && !value.IsNil() {
+			value.Elem().SetZero()
 		}
 	}
-	cobra.OnInitialize(initializer)
-	cobra.OnInitialize(initializers...)
-	return rootCmd, nil
+}
+
+// decodeConfigStruct fills cfgStruct and config sections from effective configuration. The lifecycle is the same on initial load and on reload:
+// SetDefaults methods, unmarshalling, original case of map keys, path resolution, Normalize methods, declarative constraints and Validate methods.
+// Methods are called depth-first: nested structures before their parents
+func decodeConfigStruct(result *ConfigurationResult, cfgStruct interface{}) error {
+	delimiter := result.ConfigurePlan.ConfigParsingRules.keyDelimiter()
+	setConfigDefaults(cfgStruct, delimiter)
+	for _, root := range configStructRoots(cfgStruct) {
+		if root.Prefix != "" {
+			if err := decodeConfigSection(result, root); err != nil {
+				return err
+			}
+		} else if err := result.Viper.Unmarshal(root.Struct, viper.DecodeHook(configDecodeHook(result.ConfigurePlan))); err != nil {
+			return err
+		}
+	}
+	restoreKeyCase(result, cfgStruct)
+	if err := resolveConfigPaths(result, cfgStruct); err != nil {
+		return err
+	}
+	if err := normalizeConfig(cfgStruct, delimiter); err != nil {
+		return err
+	}
+	return validateConfigStruct(cfgStruct, delimiter)
 }
 
 // configure is trying to be the main configuration function in application
@@ -80,20 +252,26 @@ func configure(
 	defaultConfig map[string]interface{},
 	cfgStruct interface{},
 ) (*ConfigurationResult, error) {
-	if configPlan.ConfigOverrideFlag != "" {
-		concreeteFiles := []string{}
-		if flagFiles, err := rootCmd.Flags().GetStringArray(configPlan.ConfigOverrideFlag); err == nil {
-			concreeteFiles = append(concreeteFiles, flagFiles...)
-		}
-		if len(concreeteFiles) > 0 {
-			configPlan.ConfigParsingRules.ConcreeteFilePaths = concreeteFiles
-		}
-	}
+	// The plan itself is not changed, so a reload picks up changed ConfigPathsEnv and flags
+	filesPlan := *configPlan
+	var configPathsSource ConfigPathsSource
+	filesPlan.ConfigParsingRules.ConcreeteFilePaths, configPathsSource = configFilePaths(rootCmd, configPlan)
 
-	vp, parsedConfigs, err := parseConfigFiles(&configPlan.ConfigParsingRules)
+	delimiter := configPlan.ConfigParsingRules.keyDelimiter()
+	vp := viper.GetViper()
+	if !configPlan.ConfigParsingRules.usesGlobalViper() {
+		vp = newViper(delimiter)
+	}
+	vp, configFiles, err := loadConfigFiles(vp, &filesPlan, cfgStruct)
 	if err != nil {
 		return nil, err
 	}
+	parsedConfigs := make([]string, 0, len(configFiles))
+	for _, file := range configFiles {
+		if file.Kind == SourceFile {
+			parsedConfigs = append(parsedConfigs, file.Path)
+		}
+	}
 
 	// Bind cmdline flags to config
 	// if !configPlan.DontBindFlagsToConfig {
@@ -105,8 +283,9 @@ func configure(
 	// Bind environment variables to config
 	if !configPlan.DontBindEnvToConfig {
 		vp.SetEnvPrefix(configPlan.EnvVariablesPrefix)
-		vp.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+		vp.SetEnvKeyReplacer(envKeyReplacer(delimiter))
 		vp.AutomaticEnv()
+		bindStructEnv(vp, configPlan, cfgStruct)
 	}
 
 	// Set default config
@@ -119,9 +298,70 @@ func configure(
 		ParsedConfigs: parsedConfigs,
 		RootCmd:       rootCmd,
 		Viper:         vp,
+		ConfigPaths:   configPathsSource,
+		configFiles:   configFiles,
+		defaultConfig: defaultConfig,
+		cfgStruct:     cfgStruct,
 	}, nil
 }
 
+// configFilePaths returns concreete config file paths from ConfigOverrideFlag, ConfigPathsEnv or the plan and their source.
+// No paths mean automatic search
+func configFilePaths(rootCmd *cobra.Command, configPlan *ConfigurePlan) ([]string, ConfigPathsSource) {
+	if configPlan.ConfigOverrideFlag != "" {
+		if flagFiles, err := rootCmd.Flags().GetStringArray(configPlan.ConfigOverrideFlag); err == nil && len(flagFiles) > 0 {
+			return flagFiles, ConfigPathsFromFlag
+		}
+	}
+	if configPlan.ConfigPathsEnv != "" {
+		if envFiles := splitConfigPaths(os.Getenv(configPlan.ConfigPathsEnv)); len(envFiles) > 0 {
+			return envFiles, ConfigPathsFromEnv
+		}
+	}
+	if len(configPlan.ConfigParsingRules.ConcreeteFilePaths) > 0 {
+		return configPlan.ConfigParsingRules.ConcreeteFilePaths, ConfigPathsFromPlan
+	}
+	return nil, ConfigPathsFromSearch
+}
+
+// loadConfigFiles parses config files and inline config of configPlan into vp applying migrations, deprecated keys and merge strategies
+func loadConfigFiles(vp *viper.Viper, configPlan *ConfigurePlan, cfgStruct interface{}) (*viper.Viper, []*configFile, error) {
+	if err := configPlan.ConfigParsingRules.checkKeyDelimiter(); err != nil {
+		return nil, nil, err
+	}
+	deprecatedKeys := collectDeprecatedKeys(configPlan, cfgStruct)
+	mergeStrategies, err := collectMergeStrategies(configPlan, cfgStruct)
+	if err != nil {
+		return nil, nil, err
+	}
+	var extraLayers []*configFile
+	inlineConfig, err := readInlineConfig(configPlan)
+	if err != nil {
+		return nil, nil, err
+	}
+	if inlineConfig != nil {
+		extraLayers = append(extraLayers, inlineConfig)
+	}
+	return parseConfigFiles(
+		vp,
+		&configPlan.ConfigParsingRules,
+		mergeStrategies,
+		extraLayers,
+		secretFilePermissionsHook(
+			configPlan.ConfigParsingRules.SecretFilePermissions,
+			collectSecretKeys(configPlan, cfgStruct),
+			configPlan.ConfigParsingRules.keyDelimiter(),
+		),
+		configMigrationsHook(configPlan),
+		deprecatedKeysHook(deprecatedKeys, configPlan.AppVersion, configPlan.ConfigParsingRules.keyDelimiter()),
+		secretFileReferencesHook(
+			configPlan.ConfigParsingRules.SecretFilePermissions,
+			collectSecretKeys(configPlan, cfgStruct),
+			configPlan.ConfigParsingRules.keyDelimiter(),
+		),
+	)
+}
+
 // // ConfigurePlan is a plan to how to configure your application
 // // It envolves commandline parsing, config parsing, binding commandline, environment and config parameters together
 // // And it contains all your defaults for Configure function to work
@@ -0,0 +1,91 @@
+package xcommon
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+func TestReloadRemovedKey(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(cfgPath, []byte("app:\n  port: 8\n  name: x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var cfg struct {
+		Port int
+		Name string
+	}
+	var result *ConfigurationResult
+	plan := &ConfigurePlan{ConfigParsingRules: ViperConfig{ConcreeteFilePaths: []string{cfgPath}, ExtractSubtree: "app"}}
+	rootCmd, err := InitCobra(func() (*cobra.Command, map[string]*pflag.Flag, error) {
+		return &cobra.Command{Use: "app", Run: func(*cobra.Command, []string) {}}, nil, nil
+	}, plan, nil, &cfg, &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootCmd.SetArgs([]string{})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != 8 || cfg.Name != "x" {
+		t.Fatalf("unexpected initial config %+v", cfg)
+	}
+
+	if err := os.WriteFile(cfgPath, []byte("app:\n  port: 9\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := result.Reload(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Port != 9 || cfg.Name != "" {
+		t.Errorf("got %+v after reload, want port 9 without name", cfg)
+	}
+	if result.Viper.IsSet("name") {
+		t.Errorf("removed key is still set: %v", result.Viper.Get("name"))
+	}
+}
+
+func TestConfigurePathsEnvKeepsPlan(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	dir := t.TempDir()
+	for name, data := range map[string]string{"a.yaml": "name: a\n", "b.yaml": "name: b\n"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	plan := &ConfigurePlan{
+		ConfigParsingRules: ViperConfig{ConcreeteFilePaths: []string{filepath.Join(dir, "a.yaml")}},
+		ConfigPathsEnv:     "XCOMMON_TEST_CONFIG",
+	}
+	rootCmd := &cobra.Command{Use: "app"}
+
+	t.Setenv("XCOMMON_TEST_CONFIG", filepath.Join(dir, "b.yaml"))
+	result, err := configure(rootCmd, plan, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ConfigPaths != ConfigPathsFromEnv || result.Viper.GetString("name") != "b" {
+		t.Errorf("got %s from %s, want b from env", result.Viper.GetString("name"), result.ConfigPaths)
+	}
+	if len(plan.ConfigParsingRules.ConcreeteFilePaths) != 1 || filepath.Base(plan.ConfigParsingRules.ConcreeteFilePaths[0]) != "a.yaml" {
+		t.Errorf("plan was changed: %v", plan.ConfigParsingRules.ConcreeteFilePaths)
+	}
+
+	viper.Reset()
+	os.Unsetenv("XCOMMON_TEST_CONFIG")
+	result, err = configure(rootCmd, plan, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ConfigPaths != ConfigPathsFromPlan || result.Viper.GetString("name") != "a" {
+		t.Errorf("got %s from %s, want a from plan", result.Viper.GetString("name"), result.ConfigPaths)
+	}
+}
@@ -0,0 +1,117 @@
+package xcommon
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// StandardDecodeHooks returns decode hooks xcommon applies when unmarshalling config into cfgStruct.
+// Supported are time.Duration, comma-separated slices, any encoding.TextUnmarshaler (net.IP, logrus.Level, time.Time, ...),
+// net.IPNet, url.URL, regexp.Regexp, os.FileMode, time.Location and byte sizes ("512MiB") for ByteSize fields
+func StandardDecodeHooks() []mapstructure.DecodeHookFunc {
+	return []mapstructure.DecodeHookFunc{
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToIPNetHookFunc(),
+		StringToURLHookFunc(),
+		StringToRegexpHookFunc(),
+		StringToFileModeHookFunc(),
+		StringToLocationHookFunc(),
+		mapstructure.TextUnmarshallerHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+		StringToByteSizeHookFunc(),
+	}
+}
+
+// configDecodeHook composes setDefaultsHook, decode hooks of configPlan and StandardDecodeHooks
+func configDecodeHook(configPlan *ConfigurePlan) mapstructure.DecodeHookFunc {
+	hooks := []mapstructure.DecodeHookFunc{setDefaultsHook()}
+	hooks = append(hooks, configPlan.DecodeHooks...)
+	hooks = append(hooks, StandardDecodeHooks()...)
+	return mapstructure.ComposeDecodeHookFunc(hooks...)
+}
+
+// setDefaultsHook calls SetDefaults of structures allocated while decoding (behind nil pointers, items of lists and maps)
+// before they are filled from config, so config values override defaults the same way as for other structures
+func setDefaultsHook() mapstructure.DecodeHookFuncValue {
+	return func(from reflect.Value, to reflect.Value) (interface{}, error) {
+		if to.Kind() == reflect.Struct && to.CanAddr() && to.IsZero() {
+			if defaulter, ok := configInterface[ConfigDefaulter](to); ok {
+				defaulter.SetDefaults()
+			}
+		}
+		return from.Interface(), nil
+	}
+}
+
+// stringHook builds a decode hook converting strings into target type (or a pointer to it) with parse function
+func stringHook(target reflect.Type, parse func(string) (interface{}, error)) mapstructure.DecodeHookFuncType {
+	return func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+		if f.Kind() != reflect.String || (t != target && t != reflect.PointerTo(target)) {
+			return data, nil
+		}
+		value, err := parse(data.(string))
+		if err != nil {
+			return nil, err
+		}
+		if t == target {
+			return reflect.ValueOf(value).Elem().Interface(), nil
+		}
+		return value, nil
+	}
+}
+
+// StringToURLHookFunc returns a decode hook converting strings into url.URL and *url.URL
+func StringToURLHookFunc() mapstructure.DecodeHookFuncType {
+	return stringHook(reflect.TypeOf(url.URL{}), func(value string) (interface{}, error) {
+		return url.Parse(value)
+	})
+}
+
+// StringToRegexpHookFunc returns a decode hook converting strings into regexp.Regexp and *regexp.Regexp
+func StringToRegexpHookFunc() mapstructure.DecodeHookFuncType {
+	return stringHook(reflect.TypeOf(regexp.Regexp{}), func(value string) (interface{}, error) {
+		return regexp.Compile(value)
+	})
+}
+
+// StringToLocationHookFunc returns a decode hook converting time zone names into time.Location and *time.Location
+func StringToLocationHookFunc() mapstructure.DecodeHookFuncType {
+	return stringHook(reflect.TypeOf(time.Location{}), func(value string) (interface{}, error) {
+		return time.LoadLocation(value)
+	})
+}
+
+// StringToFileModeHookFunc returns a decode hook converting octal strings like "0644" into os.FileMode
+func StringToFileModeHookFunc() mapstructure.DecodeHookFuncType {
+	return func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+		if f.Kind() != reflect.String || t != reflect.TypeOf(os.FileMode(0)) {
+			return data, nil
+		}
+		mode, err := strconv.ParseUint(strings.TrimPrefix(data.(string), "0o"), 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid file mode '%s'", data)
+		}
+		return os.FileMode(mode), nil
+	}
+}
+
+// StringToByteSizeHookFunc returns a decode hook converting sizes like "512MiB" into ByteSize and *ByteSize.
+// Plain integer fields are not affected, so values like "8k" or "0x10" are not taken for sizes there
+func StringToByteSizeHookFunc() mapstructure.DecodeHookFuncType {
+	return stringHook(reflect.TypeOf(ByteSize(0)), func(value string) (interface{}, error) {
+		parsed, err := parseByteSize(strings.TrimSpace(value))
+		if err != nil {
+			return nil, err
+		}
+		size := ByteSize(parsed)
+		return &size, nil
+	})
+}
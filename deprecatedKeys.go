@@ -0,0 +1,97 @@
+package xcommon
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DeprecatedKey describes a renamed config key
+// Value of OldKey is copied to NewKey if NewKey isn't set in the same config file
+type DeprecatedKey struct {
+	OldKey     string // Deprecated dotted key. Such as "server.addr"
+	NewKey     string // Dotted key that replaces OldKey. Such as "server.listen"
+	FatalSince string // Application version since which usage of OldKey is an error (see ConfigurePlan.AppVersion). Empty means never
+}
+
+// collectDeprecatedKeys returns deprecated keys from configPlan and from `deprecated:"old.key"` tags of cfgStruct.
+// Tag can contain several comma-separated old keys
+func collectDeprecatedKeys(configPlan *ConfigurePlan, cfgStruct interface{}) []DeprecatedKey {
+	ret := append([]DeprecatedKey{}, configPlan.DeprecatedKeys...)
+	walkConfigStruct(cfgStruct, configPlan.ConfigParsingRules.keyDelimiter(), func(field configStructField) bool {
+		tag, ok := field.Field.Tag.Lookup("deprecated")
+		if !ok {
+			return true
+		}
+		for _, oldKey := range strings.Split(tag, ",") {
+			if oldKey = strings.TrimSpace(oldKey); oldKey != "" {
+				ret = append(ret, DeprecatedKey{OldKey: oldKey, NewKey: field.Key})
+			}
+		}
+		return true
+	})
+	return ret
+}
+
+// deprecatedKeysHook returns config file hook that migrates deprecated keys to their new names
+func deprecatedKeysHook(deprecatedKeys []DeprecatedKey, appVersion string, delimiter string) configFileHook {
+	return func(file *configFile) error {
+		for _, deprecated := range deprecatedKeys {
+			value, ok := lookupKey(file.Settings, deprecated.OldKey, delimiter)
+			if !ok {
+				continue
+			}
+			if deprecated.FatalSince != "" && appVersion != "" && compareVersions(appVersion, deprecated.FatalSince) >= 0 {
+				return fmt.Errorf("config file '%s' uses key '%s' which was removed in version %s, use '%s' instead",
+					file.Path, deprecated.OldKey, deprecated.FatalSince, deprecated.NewKey)
+			}
+			deleteKey(file.Settings, deprecated.OldKey, delimiter)
+			if _, ok := lookupKey(file.Settings, deprecated.NewKey, delimiter); ok {
+				log.Warnf("Config file '%s' uses deprecated key '%s' together with '%s'. '%s' is ignored",
+					file.Path, deprecated.OldKey, deprecated.NewKey, deprecated.OldKey)
+				continue
+			}
+			log.Warnf("Config file '%s' uses deprecated key '%s', please rename it to '%s'",
+				file.Path, deprecated.OldKey, deprecated.NewKey)
+			setKey(file.Settings, deprecated.NewKey, value, delimiter)
+		}
+		return nil
+	}
+}
+
+// compareVersions compares two dotted versions (optional "v" prefix and pre-release suffixes are ignored).
+// Returns -1, 0 or 1
+func compareVersions(a string, b string) int {
+	aParts := versionParts(a)
+	bParts := versionParts(b)
+	for len(aParts) < len(bParts) {
+		aParts = append(aParts, 0)
+	}
+	for len(bParts) < len(aParts) {
+		bParts = append(bParts, 0)
+	}
+	for idx := range aParts {
+		if aParts[idx] < bParts[idx] {
+			return -1
+		}
+		if aParts[idx] > bParts[idx] {
+			return 1
+		}
+	}
+	return 0
+}
+
+func versionParts(version string) []int {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if idx := strings.IndexAny(version, "-+"); idx >= 0 {
+		version = version[:idx]
+	}
+	var ret []int
+	for _, part := range strings.Split(version, ".") {
+		number, _ := strconv.Atoi(part)
+		ret = append(ret, number)
+	}
+	return ret
+}
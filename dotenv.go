@@ -0,0 +1,56 @@
+package xcommon
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseDotenv parses a dotenv file: NAME=value lines with optional "export " prefix, '#' comments,
+// single-quoted (literal) and double-quoted (with escapes) values
+func parseDotenv(data []byte) (map[string]string, error) {
+	variables := map[string]string{}
+	for idx, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(strings.TrimSuffix(line, "\r"))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("line %d: expected NAME=value", idx+1)
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case strings.HasPrefix(value, "'"):
+			end := strings.Index(value[1:], "'")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated quoted value", idx+1)
+			}
+			value = value[1 : end+1]
+		case strings.HasPrefix(value, `"`):
+			end := 1
+			for end < len(value) && value[end] != '"' {
+				if value[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(value) {
+				return nil, fmt.Errorf("line %d: unterminated quoted value", idx+1)
+			}
+			unquoted, err := strconv.Unquote(value[:end+1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", idx+1, err)
+			}
+			value = unquoted
+		default:
+			if comment := strings.Index(value, " #"); comment >= 0 {
+				value = strings.TrimSpace(value[:comment])
+			}
+		}
+		variables[name] = value
+	}
+	return variables, nil
+}
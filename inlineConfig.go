@@ -0,0 +1,49 @@
+package xcommon
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const inlineConfigBase64Prefix = "base64:"
+
+// readInlineConfig parses config document from ConfigurePlan.InlineConfigEnv environment variable.
+// Returns nil if the variable is not set or empty
+func readInlineConfig(configPlan *ConfigurePlan) (*configFile, error) {
+	if configPlan.InlineConfigEnv == "" {
+		return nil, nil
+	}
+	value := strings.TrimSpace(os.Getenv(configPlan.InlineConfigEnv))
+	if value == "" {
+		return nil, nil
+	}
+
+	data := []byte(value)
+	if encoded, ok := strings.CutPrefix(value, inlineConfigBase64Prefix); ok {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("inline config in %s: invalid base64: %w", configPlan.InlineConfigEnv, err)
+		}
+		data = decoded
+	}
+	format := detectConfigFormat(data)
+	settings, err := decodeConfigData(configPlan.InlineConfigEnv, format, data, configPlan.ConfigParsingRules.keyDelimiter())
+	if err != nil {
+		return nil, err
+	}
+	if err := decryptSettings(&configPlan.ConfigParsingRules, configPlan.InlineConfigEnv, settings); err != nil {
+		return nil, err
+	}
+	file := &configFile{
+		Kind:     SourceInline,
+		Path:     configPlan.InlineConfigEnv,
+		Format:   format,
+		Settings: settings,
+	}
+	if configPlan.ConfigParsingRules.PreserveKeyCase {
+		file.Original = decodeOriginalKeys(format, data)
+	}
+	return file, nil
+}
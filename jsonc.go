@@ -0,0 +1,247 @@
+package xcommon
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonConverter converts JSONC (JSON with comments and trailing commas) and JSON5 documents into strict JSON.
+// Every output byte remembers its input offset so decoder errors can be reported at the original position
+type jsonConverter struct {
+	in      []byte
+	pos     int
+	json5   bool
+	out     []byte
+	offsets []int
+}
+
+// jsonConvertError is a conversion error at the input offset
+type jsonConvertError struct {
+	Offset int
+	Msg    string
+}
+
+func (e *jsonConvertError) Error() string {
+	return e.Msg
+}
+
+// convertToJSON converts JSONC or JSON5 data into strict JSON.
+// Returned offsets map every output byte to the input offset
+func convertToJSON(data []byte, json5 bool) ([]byte, []int, error) {
+	conv := &jsonConverter{in: data, json5: json5}
+	if err := conv.convert(); err != nil {
+		return nil, nil, err
+	}
+	return conv.out, conv.offsets, nil
+}
+
+func (conv *jsonConverter) emit(offset int, text string) {
+	for idx := 0; idx < len(text); idx++ {
+		conv.out = append(conv.out, text[idx])
+		conv.offsets = append(conv.offsets, offset)
+	}
+}
+
+func (conv *jsonConverter) fail(offset int, format string, args ...interface{}) error {
+	return &jsonConvertError{Offset: offset, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (conv *jsonConverter) convert() error {
+	for conv.pos < len(conv.in) {
+		start := conv.pos
+		c := conv.in[conv.pos]
+		switch {
+		case c == '/':
+			if err := conv.skipComment(); err != nil {
+				return err
+			}
+		case c == '"':
+			if err := conv.convertString('"'); err != nil {
+				return err
+			}
+		case c == '\'' && conv.json5:
+			if err := conv.convertString('\''); err != nil {
+				return err
+			}
+		case c == ',':
+			conv.pos++
+			if !conv.nextIsClosing() {
+				conv.emit(start, ",")
+			}
+		case conv.json5 && (c == '+' || c == '.' || c >= '0' && c <= '9' || c == '-'):
+			if err := conv.convertNumber(); err != nil {
+				return err
+			}
+		case conv.json5 && isIdentifierStart(c):
+			if err := conv.convertIdentifier(); err != nil {
+				return err
+			}
+		default:
+			conv.emit(start, string(c))
+			conv.pos++
+		}
+	}
+	return nil
+}
+
+// skipComment skips a "//" or "/* */" comment at the current position
+func (conv *jsonConverter) skipComment() error {
+	start := conv.pos
+	if conv.pos+1 >= len(conv.in) {
+		return conv.fail(start, "unexpected '/'")
+	}
+	switch conv.in[conv.pos+1] {
+	case '/':
+		end := strings.IndexByte(string(conv.in[conv.pos:]), '\n')
+		if end < 0 {
+			conv.pos = len(conv.in)
+		} else {
+			conv.pos += end
+		}
+	case '*':
+		end := strings.Index(string(conv.in[conv.pos+2:]), "*/")
+		if end < 0 {
+			return conv.fail(start, "unterminated comment")
+		}
+		conv.pos += 2 + end + 2
+		conv.emit(start, " ")
+	default:
+		return conv.fail(start, "unexpected '/'")
+	}
+	return nil
+}
+
+// nextIsClosing checks if the next significant character is '}' or ']' (the comma before it is trailing)
+func (conv *jsonConverter) nextIsClosing() bool {
+	for idx := conv.pos; idx < len(conv.in); idx++ {
+		switch c := conv.in[idx]; {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			continue
+		case c == '/' && idx+1 < len(conv.in) && conv.in[idx+1] == '/':
+			for idx < len(conv.in) && conv.in[idx] != '\n' {
+				idx++
+			}
+		case c == '/' && idx+1 < len(conv.in) && conv.in[idx+1] == '*':
+			end := strings.Index(string(conv.in[idx+2:]), "*/")
+			if end < 0 {
+				return false
+			}
+			idx += 2 + end + 1
+		default:
+			return c == '}' || c == ']'
+		}
+	}
+	return false
+}
+
+// convertString converts a string literal into a double-quoted JSON string
+func (conv *jsonConverter) convertString(quote byte) error {
+	start := conv.pos
+	conv.emit(start, `"`)
+	conv.pos++
+	for conv.pos < len(conv.in) {
+		offset := conv.pos
+		c := conv.in[conv.pos]
+		switch {
+		case c == quote:
+			conv.emit(offset, `"`)
+			conv.pos++
+			return nil
+		case c == '\\' && conv.pos+1 < len(conv.in):
+			next := conv.in[conv.pos+1]
+			conv.pos += 2
+			switch {
+			case !conv.json5:
+				conv.emit(offset, `\`+string(next))
+			case next == '\n':
+				// JSON5 line continuation
+			case next == '\r':
+				if conv.pos < len(conv.in) && conv.in[conv.pos] == '\n' {
+					conv.pos++
+				}
+			case next == '\'':
+				conv.emit(offset, "'")
+			case next == 'x' && conv.pos+2 <= len(conv.in):
+				conv.emit(offset, `\u00`+string(conv.in[conv.pos:conv.pos+2]))
+				conv.pos += 2
+			default:
+				conv.emit(offset, `\`+string(next))
+			}
+		case c == '"':
+			conv.emit(offset, `\"`)
+			conv.pos++
+		case c == '\n':
+			return conv.fail(start, "unterminated string")
+		default:
+			conv.emit(offset, string(c))
+			conv.pos++
+		}
+	}
+	return conv.fail(start, "unterminated string")
+}
+
+// convertNumber converts JSON5 numbers (hex, leading '+', leading or trailing '.') into JSON numbers
+func (conv *jsonConverter) convertNumber() error {
+	start := conv.pos
+	end := conv.pos
+	for end < len(conv.in) && strings.IndexByte("+-.0123456789abcdefABCDEFxXInfinityNa", conv.in[end]) >= 0 {
+		end++
+	}
+	literal := string(conv.in[start:end])
+	conv.pos = end
+
+	sign := ""
+	number := literal
+	if strings.HasPrefix(number, "+") || strings.HasPrefix(number, "-") {
+		if number[0] == '-' {
+			sign = "-"
+		}
+		number = number[1:]
+	}
+	switch {
+	case number == "Infinity" || number == "NaN":
+		return conv.fail(start, "'%s' can't be represented in config", literal)
+	case strings.HasPrefix(number, "0x") || strings.HasPrefix(number, "0X"):
+		value, err := strconv.ParseUint(number[2:], 16, 64)
+		if err != nil {
+			return conv.fail(start, "invalid hex number '%s'", literal)
+		}
+		number = strconv.FormatUint(value, 10)
+	default:
+		if strings.HasPrefix(number, ".") {
+			number = "0" + number
+		}
+		if strings.HasSuffix(number, ".") {
+			number += "0"
+		}
+		number = strings.Replace(number, ".e", ".0e", 1)
+		number = strings.Replace(number, ".E", ".0E", 1)
+	}
+	conv.emit(start, sign+number)
+	return nil
+}
+
+// convertIdentifier converts JSON5 unquoted keys into strings and keeps true, false and null
+func (conv *jsonConverter) convertIdentifier() error {
+	start := conv.pos
+	end := conv.pos
+	for end < len(conv.in) && (isIdentifierStart(conv.in[end]) || conv.in[end] >= '0' && conv.in[end] <= '9') {
+		end++
+	}
+	identifier := string(conv.in[start:end])
+	conv.pos = end
+	switch identifier {
+	case "true", "false", "null":
+		conv.emit(start, identifier)
+	case "Infinity", "NaN":
+		return conv.fail(start, "'%s' can't be represented in config", identifier)
+	default:
+		conv.emit(start, `"`+identifier+`"`)
+	}
+	return nil
+}
+
+func isIdentifierStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '$'
+}
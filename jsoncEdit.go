@@ -0,0 +1,331 @@
+package xcommon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// jsonNode is a value of a JSON, JSONC or JSON5 document with its position in the original text
+type jsonNode struct {
+	Start   int          // Offset of the first byte of the value
+	End     int          // Offset after the last byte of the value
+	Object  bool         // The value is an object
+	Members []jsonMember // Members of an object in order of appearance
+}
+
+// jsonMember is a member of an object
+type jsonMember struct {
+	Name     string    // Unquoted member name
+	KeyStart int       // Offset of the member name
+	Value    *jsonNode // Member value
+}
+
+// jsonScanner finds positions of values in a JSON document. Comments, trailing commas and JSON5 syntax are accepted.
+// The document must already be valid, so the scanner doesn't check everything
+type jsonScanner struct {
+	in []byte
+}
+
+// skipSpace returns offset of the next significant byte skipping whitespace and comments
+func (scanner *jsonScanner) skipSpace(pos int) int {
+	for pos < len(scanner.in) {
+		switch c := scanner.in[pos]; {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			pos++
+		case c == '/' && pos+1 < len(scanner.in) && scanner.in[pos+1] == '/':
+			for pos < len(scanner.in) && scanner.in[pos] != '\n' {
+				pos++
+			}
+		case c == '/' && pos+1 < len(scanner.in) && scanner.in[pos+1] == '*':
+			end := bytes.Index(scanner.in[pos+2:], []byte("*/"))
+			if end < 0 {
+				return len(scanner.in)
+			}
+			pos += 2 + end + 2
+		default:
+			return pos
+		}
+	}
+	return pos
+}
+
+func (scanner *jsonScanner) parseValue(pos int) (*jsonNode, error) {
+	pos = scanner.skipSpace(pos)
+	if pos >= len(scanner.in) {
+		return nil, fmt.Errorf("unexpected end of document")
+	}
+	node := &jsonNode{Start: pos}
+	switch c := scanner.in[pos]; c {
+	case '{':
+		node.Object = true
+		pos = scanner.skipSpace(pos + 1)
+		for pos < len(scanner.in) && scanner.in[pos] != '}' {
+			keyStart := pos
+			var name string
+			if c := scanner.in[pos]; c == '"' || c == '\'' {
+				end, err := scanner.stringEnd(pos)
+				if err != nil {
+					return nil, err
+				}
+				if name, err = unquoteJSONKey(string(scanner.in[pos:end])); err != nil {
+					return nil, err
+				}
+				pos = end
+			} else {
+				end := pos
+				for end < len(scanner.in) && (isIdentifierStart(scanner.in[end]) || scanner.in[end] >= '0' && scanner.in[end] <= '9') {
+					end++
+				}
+				name, pos = string(scanner.in[pos:end]), end
+			}
+			pos = scanner.skipSpace(pos)
+			if pos >= len(scanner.in) || scanner.in[pos] != ':' {
+				return nil, fmt.Errorf("':' expected at offset %d", pos)
+			}
+			value, err := scanner.parseValue(pos + 1)
+			if err != nil {
+				return nil, err
+			}
+			node.Members = append(node.Members, jsonMember{Name: name, KeyStart: keyStart, Value: value})
+			if pos = scanner.skipSpace(value.End); pos < len(scanner.in) && scanner.in[pos] == ',' {
+				pos = scanner.skipSpace(pos + 1)
+			}
+		}
+		if pos >= len(scanner.in) {
+			return nil, fmt.Errorf("unterminated object at offset %d", node.Start)
+		}
+		node.End = pos + 1
+	case '[':
+		pos = scanner.skipSpace(pos + 1)
+		for pos < len(scanner.in) && scanner.in[pos] != ']' {
+			value, err := scanner.parseValue(pos)
+			if err != nil {
+				return nil, err
+			}
+			if pos = scanner.skipSpace(value.End); pos < len(scanner.in) && scanner.in[pos] == ',' {
+				pos = scanner.skipSpace(pos + 1)
+			}
+		}
+		if pos >= len(scanner.in) {
+			return nil, fmt.Errorf("unterminated array at offset %d", node.Start)
+		}
+		node.End = pos + 1
+	case '"', '\'':
+		end, err := scanner.stringEnd(pos)
+		if err != nil {
+			return nil, err
+		}
+		node.End = end
+	default:
+		end := pos
+		for end < len(scanner.in) && !strings.ContainsRune(",}] \t\r\n/", rune(scanner.in[end])) {
+			end++
+		}
+		node.End = end
+	}
+	return node, nil
+}
+
+// stringEnd returns offset after the closing quote of a string starting at pos
+func (scanner *jsonScanner) stringEnd(pos int) (int, error) {
+	quote := scanner.in[pos]
+	for idx := pos + 1; idx < len(scanner.in); idx++ {
+		switch scanner.in[idx] {
+		case '\\':
+			idx++
+		case quote:
+			return idx + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("unterminated string at offset %d", pos)
+}
+
+// unquoteJSONKey unquotes a double or single quoted member name
+func unquoteJSONKey(quoted string) (string, error) {
+	if quoted[0] == '\'' {
+		quoted = `"` + strings.ReplaceAll(strings.ReplaceAll(quoted[1:len(quoted)-1], `\'`, `'`), `"`, `\"`) + `"`
+	}
+	var name string
+	if err := json.Unmarshal([]byte(quoted), &name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// setJSONValues updates keys in a JSON, JSONC or JSON5 document by editing its text, so comments and formatting are kept
+func setJSONValues(data []byte, format string, values map[string]interface{}, delimiter string) ([]byte, error) {
+	text := string(data)
+	if strings.TrimSpace(text) == "" {
+		text = "{}\n"
+	}
+	for _, key := range sortedKeys(values) {
+		var err error
+		if text, err = setJSONValue(text, splitKey(key, delimiter), values[key]); err != nil {
+			return nil, fmt.Errorf("key '%s': %w", key, err)
+		}
+	}
+	if _, err := decodeConfigData("", format, []byte(text), delimiter); err != nil {
+		return nil, fmt.Errorf("updated document is not valid %s: %w", strings.ToUpper(format), err)
+	}
+	return []byte(text), nil
+}
+
+func setJSONValue(text string, path []string, value interface{}) (string, error) {
+	scanner := &jsonScanner{in: []byte(text)}
+	node, err := scanner.parseValue(0)
+	if err != nil {
+		return "", err
+	}
+	if !node.Object {
+		return "", fmt.Errorf("root of the document is not an object")
+	}
+	for idx, part := range path {
+		memberIdx := -1
+		for i, member := range node.Members {
+			if strings.ToLower(member.Name) == part {
+				memberIdx = i
+			}
+		}
+		last := idx == len(path)-1
+		switch {
+		case memberIdx < 0 && value == nil:
+			return text, nil
+		case memberIdx < 0:
+			nested := value
+			for i := len(path) - 1; i > idx; i-- {
+				nested = map[string]interface{}{path[i]: nested}
+			}
+			return insertJSONMember(text, node, part, nested)
+		case last && value == nil:
+			return deleteJSONMember(text, node, memberIdx), nil
+		case last:
+			member := node.Members[memberIdx]
+			encoded, err := encodeJSONValue(value, lineIndent(text, member.KeyStart), detectIndent([]byte(text)))
+			if err != nil {
+				return "", err
+			}
+			return text[:member.Value.Start] + encoded + text[member.Value.End:], nil
+		case !node.Members[memberIdx].Value.Object:
+			return "", fmt.Errorf("'%s' is not an object", part)
+		}
+		node = node.Members[memberIdx].Value
+	}
+	return text, nil
+}
+
+// insertJSONMember adds a member at the end of an object keeping the layout of the object
+func insertJSONMember(text string, node *jsonNode, name string, value interface{}) (string, error) {
+	unit := detectIndent([]byte(text))
+	multiline := strings.Contains(text[node.Start:node.End], "\n") || len(node.Members) == 0
+	indent := lineIndent(text, node.Start) + strings.Repeat(" ", unit)
+	if len(node.Members) > 0 {
+		indent = lineIndent(text, node.Members[0].KeyStart)
+	}
+	encodedName, err := json.Marshal(name)
+	if err != nil {
+		return "", err
+	}
+	encoded, err := encodeJSONValue(value, indent, unit)
+	if !multiline {
+		var compact bytes.Buffer
+		if err == nil {
+			err = json.Compact(&compact, []byte(encoded))
+		}
+		encoded = compact.String()
+	}
+	if err != nil {
+		return "", err
+	}
+	member := string(encodedName) + ": " + encoded
+
+	if len(node.Members) == 0 {
+		return text[:node.Start] + "{\n" + indent + member + "\n" + lineIndent(text, node.Start) + "}" + text[node.End:], nil
+	}
+	scanner := &jsonScanner{in: []byte(text)}
+	valueEnd := node.Members[len(node.Members)-1].Value.End
+	next := scanner.skipSpace(valueEnd)
+	trailingComma := next < len(text) && text[next] == ','
+	if !multiline {
+		if trailingComma {
+			return text[:next+1] + " " + member + "," + text[next+1:], nil
+		}
+		return text[:valueEnd] + ", " + member + text[valueEnd:], nil
+	}
+
+	// Insert on a new line after the last member and its comments on the same line
+	insertAt := valueEnd
+	if trailingComma {
+		insertAt = next + 1
+	}
+	lineEnd := strings.IndexByte(text[insertAt:], '\n')
+	if lineEnd >= 0 {
+		rest := strings.TrimSpace(text[insertAt : insertAt+lineEnd])
+		if rest == "" || strings.HasPrefix(rest, "//") {
+			insertAt += lineEnd
+		}
+	}
+	if trailingComma {
+		return text[:insertAt] + "\n" + indent + member + "," + text[insertAt:], nil
+	}
+	return text[:valueEnd] + "," + text[valueEnd:insertAt] + "\n" + indent + member + text[insertAt:], nil
+}
+
+// deleteJSONMember removes a member with its comma. Lines left empty are removed too
+func deleteJSONMember(text string, node *jsonNode, memberIdx int) string {
+	member := node.Members[memberIdx]
+	start, end := member.KeyStart, member.Value.End
+	scanner := &jsonScanner{in: []byte(text)}
+	next := scanner.skipSpace(end)
+	hasComma := next < len(text) && text[next] == ','
+	if hasComma {
+		end = next + 1
+	} else if memberIdx > 0 {
+		// The last member: remove the comma after the previous member
+		prevEnd := node.Members[memberIdx-1].Value.End
+		if comma := scanner.skipSpace(prevEnd); comma < start && text[comma] == ',' {
+			text = text[:comma] + text[comma+1:]
+			start, end = start-1, end-1
+		}
+	}
+	lineStart := strings.LastIndexByte(text[:start], '\n') + 1
+	if strings.TrimSpace(text[lineStart:start]) == "" {
+		// The member occupies whole lines: remove them with a comment on the last line
+		if lineEnd := strings.IndexByte(text[end:], '\n'); lineEnd >= 0 {
+			if rest := strings.TrimSpace(text[end : end+lineEnd]); rest == "" || strings.HasPrefix(rest, "//") {
+				return text[:lineStart] + text[end+lineEnd+1:]
+			}
+		}
+	}
+	// The member shares the line with other members: remove spaces between them too
+	if hasComma {
+		for end < len(text) && text[end] == ' ' {
+			end++
+		}
+	} else {
+		for start > 0 && text[start-1] == ' ' {
+			start--
+		}
+	}
+	return text[:start] + text[end:]
+}
+
+// encodeJSONValue encodes value as JSON indented relative to the line with indent
+func encodeJSONValue(value interface{}, indent string, unit int) (string, error) {
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent(indent, strings.Repeat(" ", unit))
+	if err := encoder.Encode(value); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(out.String(), "\n"), nil
+}
+
+// lineIndent returns leading whitespace of the line containing offset
+func lineIndent(text string, offset int) string {
+	lineStart := strings.LastIndexByte(text[:offset], '\n') + 1
+	line := text[lineStart:]
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
@@ -0,0 +1,136 @@
+package xcommon
+
+import (
+	"testing"
+)
+
+func TestSetJSONValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		in     string
+		values map[string]interface{}
+		want   string
+	}{
+		{
+			"replace value keeping comments",
+			"jsonc",
+			"{\n  // server\n  \"server\": {\n    \"port\": 1, // port\n  },\n}\n",
+			map[string]interface{}{"server.port": 8080},
+			"{\n  // server\n  \"server\": {\n    \"port\": 8080, // port\n  },\n}\n",
+		},
+		{
+			"keys are case-insensitive",
+			"json",
+			"{\n  \"Server\": {\n    \"Port\": 1\n  }\n}\n",
+			map[string]interface{}{"server.port": 2},
+			"{\n  \"Server\": {\n    \"Port\": 2\n  }\n}\n",
+		},
+		{
+			"add member after a trailing comma",
+			"jsonc",
+			"{\n  \"a\": 1, // one\n}\n",
+			map[string]interface{}{"b": "x"},
+			"{\n  \"a\": 1, // one\n  \"b\": \"x\",\n}\n",
+		},
+		{
+			"add member without a trailing comma",
+			"json",
+			"{\n    \"a\": 1\n}\n",
+			map[string]interface{}{"b": true},
+			"{\n    \"a\": 1,\n    \"b\": true\n}\n",
+		},
+		{
+			"add nested member",
+			"json",
+			"{\n  \"a\": 1\n}\n",
+			map[string]interface{}{"b.c": 2},
+			"{\n  \"a\": 1,\n  \"b\": {\n    \"c\": 2\n  }\n}\n",
+		},
+		{
+			"add member into an empty object",
+			"json",
+			"{\n  \"a\": {}\n}\n",
+			map[string]interface{}{"a.b": 1},
+			"{\n  \"a\": {\n    \"b\": 1\n  }\n}\n",
+		},
+		{
+			"add member into a single-line object",
+			"json",
+			`{"a": 1}`,
+			map[string]interface{}{"b": []interface{}{1, "x"}},
+			`{"a": 1, "b": [1,"x"]}`,
+		},
+		{
+			"create document",
+			"json",
+			"",
+			map[string]interface{}{"a": 1},
+			"{\n  \"a\": 1\n}\n",
+		},
+		{
+			"remove member with its line comment",
+			"jsonc",
+			"{\n  \"a\": 1,\n  \"b\": 2, // two\n  \"c\": 3\n}\n",
+			map[string]interface{}{"b": nil},
+			"{\n  \"a\": 1,\n  \"c\": 3\n}\n",
+		},
+		{
+			"remove the last member",
+			"json",
+			"{\n  \"a\": 1,\n  \"b\": 2\n}\n",
+			map[string]interface{}{"b": nil},
+			"{\n  \"a\": 1\n}\n",
+		},
+		{
+			"remove members of a single-line object",
+			"json",
+			`{"a": 1, "b": 2, "c": 3}`,
+			map[string]interface{}{"a": nil, "c": nil},
+			`{"b": 2}`,
+		},
+		{
+			"remove a missing member",
+			"json",
+			`{"a": 1}`,
+			map[string]interface{}{"b.c": nil},
+			`{"a": 1}`,
+		},
+		{
+			"json5 unquoted and single-quoted keys",
+			"json5",
+			"{\n  server: {port: 1},\n  'name': 'a', // name\n}\n",
+			map[string]interface{}{"server.port": 0x10, "name": nil},
+			"{\n  server: {port: 16},\n}\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := setJSONValues([]byte(test.in), test.format, test.values, ".")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(got) != test.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSetJSONValuesErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     string
+		values map[string]interface{}
+	}{
+		{"root is not an object", `[1, 2]`, map[string]interface{}{"a": 1}},
+		{"parent is not an object", `{"a": 1}`, map[string]interface{}{"a.b": 1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := setJSONValues([]byte(test.in), "json", test.values, "."); err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
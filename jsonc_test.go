@@ -0,0 +1,109 @@
+package xcommon
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestConvertToJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		in    string
+		json5 bool
+		want  string
+	}{
+		{"plain json", `{"a": [1, 2.5, "x"], "b": null}`, false, `{"a": [1, 2.5, "x"], "b": null}`},
+		{"line comment", "{\n  // comment\n  \"a\": 1 // trailing\n}", false, `{"a": 1}`},
+		{"block comment", "{/* a */ \"a\": /* b\n c */ 1}", false, `{"a": 1}`},
+		{"comment markers in strings", `{"url": "http://x/*y*/", "c": "// no"}`, false, `{"url": "http://x/*y*/", "c": "// no"}`},
+		{"trailing comma in object", `{"a": 1, "b": 2,}`, false, `{"a": 1, "b": 2}`},
+		{"trailing comma in array", `{"a": [1, 2, ]}`, false, `{"a": [1, 2]}`},
+		{"trailing comma before comment", "{\"a\": [1, // one\n]}", false, `{"a": [1]}`},
+		{"escapes in jsonc", `{"a": "q\"uote\\n"}`, false, `{"a": "q\"uote\\n"}`},
+		{"json5 unquoted keys", `{a: 1, $b_2: true, c: null}`, true, `{"a": 1, "$b_2": true, "c": null}`},
+		{"json5 single quotes", `{'a': 'it\'s "x"'}`, true, `{"a": "it's \"x\""}`},
+		{"json5 hex escape", `{a: '\x41'}`, true, `{"a": "A"}`},
+		{"json5 line continuation", "{a: 'one \\\ntwo'}", true, `{"a": "one two"}`},
+		{"json5 hex numbers", `{a: 0x1F, b: -0x10}`, true, `{"a": 31, "b": -16}`},
+		{"json5 leading plus", `{a: +1}`, true, `{"a": 1}`},
+		{"json5 leading and trailing dot", `{a: .5, b: 5., c: 1.e3}`, true, `{"a": 0.5, "b": 5.0, "c": 1.0e3}`},
+		{"json5 trailing commas", `{a: [1,], b: 2,}`, true, `{"a": [1], "b": 2}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out, offsets, err := convertToJSON([]byte(test.in), test.json5)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(offsets) != len(out) {
+				t.Fatalf("got %d offsets for %d bytes", len(offsets), len(out))
+			}
+			var got, want interface{}
+			if err := json.Unmarshal(out, &got); err != nil {
+				t.Fatalf("converted document %q is not valid JSON: %v", out, err)
+			}
+			if err := json.Unmarshal([]byte(test.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %s, want %s", out, test.want)
+			}
+		})
+	}
+}
+
+func TestConvertToJSONErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     string
+		json5  bool
+		offset int
+	}{
+		{"unterminated block comment", `{"a": 1 /* comment`, false, 8},
+		{"single slash", `{"a": / 1}`, false, 6},
+		{"unterminated string", "{\"a\": \"abc\n}", false, 6},
+		{"json5 infinity", `{a: Infinity}`, true, 4},
+		{"json5 nan", `{a: -NaN}`, true, 4},
+		{"json5 invalid hex", `{a: 0xZ}`, true, 4},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := convertToJSON([]byte(test.in), test.json5)
+			var convertErr *jsonConvertError
+			if !errors.As(err, &convertErr) {
+				t.Fatalf("expected conversion error, got %v", err)
+			}
+			if convertErr.Offset != test.offset {
+				t.Errorf("got offset %d, want %d (%v)", convertErr.Offset, test.offset, err)
+			}
+		})
+	}
+}
+
+func TestDecodeConfigDataErrorPosition(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		in     string
+		line   int
+		column int
+	}{
+		{"jsonc syntax error after comments", "jsonc", "{\n  // comment\n  /* block */ \"a\": 1,\n  \"b\": ]\n}", 4, 8},
+		{"json5 syntax error after converted values", "json5", "{\n  a: 0x10,\n  'b': 'x',\n  c: }\n}", 4, 6},
+		{"jsonc conversion error", "jsonc", "{\n  \"a\": 1 /* comment\n}", 2, 10},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := decodeConfigData("config."+test.format, test.format, []byte(test.in), ".")
+			var parseErr *ConfigParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("expected ConfigParseError, got %v", err)
+			}
+			if parseErr.Line != test.line || parseErr.Column != test.column {
+				t.Errorf("got position %d:%d, want %d:%d (%v)", parseErr.Line, parseErr.Column, test.line, test.column, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package xcommon
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// MergeStrategy defines how a value of a config key is merged with the same key from previous config sources
+type MergeStrategy string
+
+const (
+	MergeReplace      MergeStrategy = "replace"       // Value replaces the previous one. Default for lists and scalars
+	MergeAppend       MergeStrategy = "append"        // List items are appended to the previous list
+	MergePrepend      MergeStrategy = "prepend"       // List items are prepended to the previous list
+	MergeUniqueAppend MergeStrategy = "unique-append" // List items that are not in the previous list yet are appended
+	MergeDeep         MergeStrategy = "deep"          // Maps are merged key by key recursively. Default for maps
+
+	mergeByPrefix = "merge-by="
+)
+
+// MergeBy returns a strategy that merges lists of objects by idField.
+// Objects with the same id are deep-merged, new objects are appended
+func MergeBy(idField string) MergeStrategy {
+	return MergeStrategy(mergeByPrefix + idField)
+}
+
+func (strategy MergeStrategy) validate() error {
+	switch strategy {
+	case MergeReplace, MergeAppend, MergePrepend, MergeUniqueAppend, MergeDeep:
+		return nil
+	}
+	if strings.HasPrefix(string(strategy), mergeByPrefix) && len(strategy) > len(mergeByPrefix) {
+		return nil
+	}
+	return fmt.Errorf("unknown merge strategy '%s'", strategy)
+}
+
+// collectMergeStrategies returns merge strategies from configPlan and from `merge:"append"` tags of cfgStruct.
+// Keys are lowercased dotted config keys
+func collectMergeStrategies(configPlan *ConfigurePlan, cfgStruct interface{}) (map[string]MergeStrategy, error) {
+	ret := map[string]MergeStrategy{}
+	var err error
+	walkConfigStruct(cfgStruct, configPlan.ConfigParsingRules.keyDelimiter(), func(field configStructField) bool {
+		if tag, ok := field.Field.Tag.Lookup("merge"); ok {
+			ret[field.Key] = MergeStrategy(tag)
+		}
+		return true
+	})
+	for key, strategy := range configPlan.MergeStrategies {
+		ret[strings.ToLower(key)] = strategy
+	}
+	for key, strategy := range ret {
+		if vErr := strategy.validate(); vErr != nil && err == nil {
+			err = fmt.Errorf("config key '%s': %w", key, vErr)
+		}
+	}
+	return ret, err
+}
+
+// mergeSettings merges src settings into dst according to strategies
+func mergeSettings(dst map[string]interface{}, src map[string]interface{}, prefix string, strategies map[string]MergeStrategy, delimiter string) {
+	for srcKey, srcValue := range src {
+		dstKey := findMapKey(dst, strings.ToLower(srcKey))
+		key := joinKey(prefix, strings.ToLower(srcKey), delimiter)
+		dstValue, exists := dst[dstKey]
+		if !exists {
+			dst[dstKey] = srcValue
+			continue
+		}
+		dst[dstKey] = mergeValues(dstValue, srcValue, key, strategies, delimiter)
+	}
+}
+
+func mergeValues(dstValue interface{}, srcValue interface{}, key string, strategies map[string]MergeStrategy, delimiter string) interface{} {
+	strategy := strategies[key]
+	if strategy == MergeReplace {
+		return srcValue
+	}
+
+	if srcMap, ok := toStringMap(srcValue); ok {
+		dstMap, ok := toStringMap(dstValue)
+		if !ok {
+			return srcValue
+		}
+		mergeSettings(dstMap, srcMap, key, strategies, delimiter)
+		return dstMap
+	}
+
+	srcList, srcOk := toList(srcValue)
+	dstList, dstOk := toList(dstValue)
+	if !srcOk || !dstOk {
+		return srcValue
+	}
+	switch {
+	case strategy == MergeAppend:
+		return append(dstList, srcList...)
+	case strategy == MergePrepend:
+		return append(srcList, dstList...)
+	case strategy == MergeUniqueAppend:
+		for _, item := range srcList {
+			if !listContains(dstList, item) {
+				dstList = append(dstList, item)
+			}
+		}
+		return dstList
+	case strings.HasPrefix(string(strategy), mergeByPrefix):
+		return mergeListsBy(dstList, srcList, strings.TrimPrefix(string(strategy), mergeByPrefix), delimiter)
+	default:
+		return srcValue
+	}
+}
+
+// mergeListsBy merges lists of objects by idField. Objects with the same id are deep-merged
+func mergeListsBy(dstList []interface{}, srcList []interface{}, idField string, delimiter string) []interface{} {
+	for _, srcItem := range srcList {
+		srcId, ok := listItemId(srcItem, idField, delimiter)
+		if !ok {
+			dstList = append(dstList, srcItem)
+			continue
+		}
+		merged := false
+		for idx, dstItem := range dstList {
+			if dstId, ok := listItemId(dstItem, idField, delimiter); ok && dstId == srcId {
+				dstList[idx] = mergeValues(dstItem, srcItem, "", nil, delimiter)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			dstList = append(dstList, srcItem)
+		}
+	}
+	return dstList
+}
+
+func listItemId(item interface{}, idField string, delimiter string) (string, bool) {
+	itemMap, ok := toStringMap(item)
+	if !ok {
+		return "", false
+	}
+	id, ok := lookupKey(itemMap, idField, delimiter)
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprint(id), true
+}
+
+// toList converts lists of any item type to []interface{}. Strings of env variables and dotenv files
+// are split by commas the same way StringToSliceHookFunc does
+func toList(value interface{}) ([]interface{}, bool) {
+	if s, ok := value.(string); ok {
+		if s == "" {
+			return []interface{}{}, true
+		}
+		value = strings.Split(s, ",")
+	}
+	rv := reflect.ValueOf(value)
+	if !rv.IsValid() || rv.Kind() != reflect.Slice {
+		return nil, false
+	}
+	ret := make([]interface{}, rv.Len())
+	for idx := range ret {
+		ret[idx] = rv.Index(idx).Interface()
+	}
+	return ret, true
+}
+
+func listContains(list []interface{}, value interface{}) bool {
+	for _, item := range list {
+		if reflect.DeepEqual(item, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,11 +1,13 @@
 package xcommon
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/spf13/viper"
@@ -13,71 +15,219 @@ import (
 
 // ViperConfig describes how config files will be searched and loaded
 type ViperConfig struct {
-	SearchDirs           []string // Dirs for automatic search, '.' always included implicitly
-	SearchFiles          []string // Filenames (without paths) for automatic search (all found files will be merged)
-	SearchAtLeastOneFile bool     // If true and no files are found in automatic mode, it will fail
-	ConcreeteFilePaths   []string // Concreete paths with configs. Auto searching is not working here. Only first config in this list must exist, others are optional
-	ExtractSubtree       string   // Extract a subtree from parsed config
+	SearchDirs            []string             // Dirs for automatic search, '.' always included implicitly
+	SearchFiles           []string             // Filenames (without paths) for automatic search (all found files will be merged)
+	SearchAtLeastOneFile  bool                 // If true and no files are found in automatic mode, it will fail
+	ConcreeteFilePaths    []string             // Concreete paths with configs. Auto searching is not working here. Only first config in this list must exist, others are optional. "format:path" overrides format, "-" is standard input
+	ExtractSubtree        string               // Extract a subtree from parsed config
+	RenderTemplates       bool                 // Render all config files with text/template before parsing. Files with ".tmpl" suffix (e.g. "config.yaml.tmpl") are always rendered
+	UserConfigFile        string               // User-level config file (such as "~/.config/app/config.yaml"). Optionally loaded after SearchFiles in automatic mode. Target of ConfigurationResult.SetConfigValues
+	EncryptionKeyFile     string               // File with base64-encoded AES key for encrypted config files and "ENC[...]" values
+	EncryptionKeyEnv      string               // Environment variable with base64-encoded AES key. Has precedence over EncryptionKeyFile
+	SecretFilePermissions FilePermissionPolicy // Check that config files with secret values, files of "file:" secrets, --set-file files of secret keys and the encryption key file are not accessible by other users
+	KeyDelimiter          string               // Separator of config key components instead of "." (such as "::"), so map keys like "api.example.com" are kept whole. Applies to all dotted keys of the plan, tags, --set and Viper.Get. Global viper is not used then
+	PreserveKeyCase       bool                 // Keep original case of map keys (such as "X-Request-ID") from YAML, JSON and TOML config files when unmarshalling into cfgStruct. Config keys themselves stay case-insensitive
 }
 
-func parseConfigFiles(viperConfig *ViperConfig) (*viper.Viper, []string, error) {
-	var configsLoaded []string // list of parsed config filepaths
+// keyDelimiter returns the configured key delimiter or "."
+func (viperConfig *ViperConfig) keyDelimiter() string {
+	if viperConfig.KeyDelimiter == "" {
+		return "."
+	}
+	return viperConfig.KeyDelimiter
+}
+
+// usesGlobalViper checks if configuration is loaded into the global viper instance. It always splits keys on dots,
+// so other delimiters use a separate instance
+func (viperConfig *ViperConfig) usesGlobalViper() bool {
+	return viperConfig.keyDelimiter() == "."
+}
+
+// checkKeyDelimiter rejects key delimiters that conflict with --set syntax
+func (viperConfig *ViperConfig) checkKeyDelimiter() error {
+	if strings.ContainsAny(viperConfig.KeyDelimiter, " =[]") {
+		return fmt.Errorf("invalid config key delimiter '%s'", viperConfig.KeyDelimiter)
+	}
+	return nil
+}
+
+// configFile is a single parsed configuration file
+type configFile struct {
+	Kind      ValueSourceKind        // Kind of the source: SourceFile for config files or SourceInline for inline configs
+	Path      string                 // Path to the file (environment variable name for inline configs)
+	Format    string                 // Config format (yaml, json, toml, ...)
+	Settings  map[string]interface{} // Parsed settings of this file only
+	Encrypted map[string]bool        // Dotted keys of values that were encrypted in the file. "" means the whole file was encrypted
+	Original  map[string]interface{} // Settings with original case of keys. Only with ViperConfig.PreserveKeyCase
+}
+
+// configFileHook is called for every parsed config file before it's merged into the resulting config
+type configFileHook func(file *configFile) error
+
+// parseConfigFiles parses one or more config files into vp. Returns Viper instance with parsed configs, list of parsed config files or error
+// Every file is parsed separately, passed through hooks and then merged in order of appearance according to mergeStrategies.
+// extraLayers (such as inline configs) are merged after config files
+func parseConfigFiles(
+	vp *viper.Viper,
+	viperConfig *ViperConfig,
+	mergeStrategies map[string]MergeStrategy,
+	extraLayers []*configFile,
+	hooks ...configFileHook,
+) (*viper.Viper, []*configFile, error) {
+	var configsLoaded []*configFile // list of parsed config files
 
 	if len(viperConfig.ConcreeteFilePaths) > 0 {
 		// Manual mode
 		for _, cfgPath := range viperConfig.ConcreeteFilePaths {
-			_, cfgPathErr := os.Stat(cfgPath)
-			if errors.Is(cfgPathErr, fs.ErrNotExist) && len(configsLoaded) == 0 {
+			statPath, _ := splitFormatPrefix(cfgPath)
+			_, cfgPathErr := os.Stat(statPath)
+			if errors.Is(cfgPathErr, fs.ErrNotExist) && len(configsLoaded) == 0 && statPath != stdinConfigPath {
 				return nil, configsLoaded, fmt.Errorf("specified configuration file '%s' doesn't exist", cfgPath)
 			}
-			viper.SetConfigFile(cfgPath)
-			if len(configsLoaded) == 0 {
-				if err := viper.ReadInConfig(); err != nil {
-					return nil, configsLoaded, err
-				}
-			} else {
-				if err := viper.MergeInConfig(); err != nil {
-					return nil, configsLoaded, err
-				}
+			file, err := readConfigFile(viperConfig, cfgPath)
+			if err != nil {
+				return nil, configsLoaded, err
 			}
-			configsLoaded = append(configsLoaded, cfgPath)
+			configsLoaded = append(configsLoaded, file)
 		}
 	} else {
 		// Auto mode
-		viper.AddConfigPath(".")
-		for _, searchDir := range viperConfig.SearchDirs {
-			viper.AddConfigPath(searchDir)
-		}
+		searchDirs := append([]string{"."}, viperConfig.SearchDirs...)
 		for _, fileName := range viperConfig.SearchFiles {
-			ext := filepath.Ext(fileName)
-			viper.SetConfigType(strings.TrimLeft(ext, "."))
-			viper.SetConfigName(strings.TrimSuffix(fileName, ext))
-			if len(configsLoaded) == 0 {
-				if err := viper.ReadInConfig(); err != nil {
-					continue // In auto mode first file is not mandatory
-				}
-			} else {
-				if err := viper.MergeInConfig(); err != nil {
-					continue // In auto mode all files are not mandatory
+			cfgPath := findConfigFile(searchDirs, fileName)
+			if cfgPath == "" {
+				continue // In auto mode all files are not mandatory
+			}
+			file, err := readConfigFile(viperConfig, cfgPath)
+			if err != nil {
+				return nil, configsLoaded, err // But found files must be valid
+			}
+			configsLoaded = append(configsLoaded, file)
+		}
+		if userConfigFile := expandPath(viperConfig.UserConfigFile); userConfigFile != "" {
+			if _, err := os.Stat(userConfigFile); err == nil {
+				file, err := readConfigFile(viperConfig, userConfigFile)
+				if err != nil {
+					return nil, configsLoaded, err
 				}
+				configsLoaded = append(configsLoaded, file)
 			}
-			configsLoaded = append(configsLoaded, fileName)
 		}
-		if len(configsLoaded) == 0 && viperConfig.SearchAtLeastOneFile {
+		if len(configsLoaded) == 0 && len(extraLayers) == 0 && viperConfig.SearchAtLeastOneFile {
 			return nil, configsLoaded, fmt.Errorf("no configuration files were found")
 		}
 	}
+	configsLoaded = append(configsLoaded, extraLayers...)
+
+	merged := map[string]interface{}{}
+	for _, file := range configsLoaded {
+		for _, hook := range hooks {
+			if err := hook(file); err != nil {
+				return nil, configsLoaded, err
+			}
+		}
+		mergeSettings(merged, copySettings(file.Settings), "", mergeStrategies, viperConfig.keyDelimiter())
+	}
+	if err := vp.MergeConfigMap(merged); err != nil {
+		return nil, configsLoaded, err
+	}
 
-	outViper := viper.GetViper()
+	outViper := vp
 	if viperConfig.ExtractSubtree != "" {
-		outViper = viper.Sub(viperConfig.ExtractSubtree)
+		outViper = vp.Sub(viperConfig.ExtractSubtree)
 		if outViper == nil {
-			outViper = viper.New()
+			outViper = newViper(viperConfig.keyDelimiter())
 		}
 	}
 	return outViper, configsLoaded, nil
 }
 
+// findConfigFile returns the first existing path of fileName in searchDirs or empty string
+func findConfigFile(searchDirs []string, fileName string) string {
+	for _, searchDir := range searchDirs {
+		cfgPath := filepath.Join(searchDir, fileName)
+		if stat, err := os.Stat(cfgPath); err == nil && !stat.IsDir() {
+			return cfgPath
+		}
+	}
+	return ""
+}
+
+// readConfigFile reads and parses a single config file.
+// Format is determined by "format:" prefix of cfgPath, by file extension or by contents. "-" means standard input
+func readConfigFile(viperConfig *ViperConfig, cfgPath string) (*configFile, error) {
+	cfgPath, format := splitFormatPrefix(cfgPath)
+	data, err := readConfigData(cfgPath)
+	if err != nil {
+		return nil, err
+	}
+	encrypted := map[string]bool{"": isEncrypted(string(data))}
+	if data, err = decryptConfigData(viperConfig, cfgPath, data); err != nil {
+		return nil, err
+	}
+	if isTemplateFile(viperConfig, cfgPath) {
+		if data, err = renderConfigTemplate(cfgPath, data); err != nil {
+			return nil, err
+		}
+	}
+	if format == "" {
+		format = configFileFormat(cfgPath, data)
+	}
+	settings, err := decodeConfigData(cfgPath, format, data, viperConfig.keyDelimiter())
+	if err != nil {
+		return nil, err
+	}
+	for key := range encryptedKeys(settings, "", viperConfig.keyDelimiter()) {
+		encrypted[key] = true
+	}
+	if err := decryptSettings(viperConfig, cfgPath, settings); err != nil {
+		return nil, err
+	}
+	file := &configFile{
+		Kind:      SourceFile,
+		Path:      cfgPath,
+		Format:    format,
+		Settings:  settings,
+		Encrypted: encrypted,
+	}
+	if viperConfig.PreserveKeyCase {
+		file.Original = decodeOriginalKeys(format, data)
+	}
+	return file, nil
+}
+
+// decodeConfigData parses config data of a given format into a nested settings map
+func decodeConfigData(cfgPath string, format string, data []byte, delimiter string) (map[string]interface{}, error) {
+	decodeData := data
+	var offsets []int
+	if format == "jsonc" || format == "json5" {
+		var err error
+		if decodeData, offsets, err = convertToJSON(data, format == "json5"); err != nil {
+			var convertErr *jsonConvertError
+			if errors.As(err, &convertErr) {
+				return nil, newConfigParseErrorAt(cfgPath, data, convertErr.Offset, err)
+			}
+			return nil, err
+		}
+		format = "json"
+	}
+	if !slices.Contains(viper.SupportedExts, format) {
+		return nil, fmt.Errorf("config file '%s' has unsupported format '%s'", cfgPath, format)
+	}
+
+	fileViper := newViper(delimiter)
+	fileViper.SetConfigType(format)
+	if err := fileViper.ReadConfig(bytes.NewReader(decodeData)); err != nil {
+		if offsets != nil {
+			if offset, ok := jsonErrorOffset(err); ok {
+				return nil, newConfigParseErrorAt(cfgPath, data, mapJSONOffset(offsets, offset), err)
+			}
+		}
+		return nil, newConfigParseError(cfgPath, data, err)
+	}
+	return fileViper.AllSettings(), nil
+}
+
 // // ViperConfig describes how config files will be searched and loaded
 // type ViperConfig struct {
 // 	SearchDirs           []string // Dirs for automatic search, '.' always included implicitly
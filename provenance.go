@@ -0,0 +1,112 @@
+package xcommon
+
+import (
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// ValueSourceKind is a kind of source that supplied a config value
+type ValueSourceKind string
+
+const (
+	SourceDefault ValueSourceKind = "default" // Value from default config
+	SourceFile    ValueSourceKind = "file"    // Value from a config file
+	SourceInline  ValueSourceKind = "inline"  // Value from an inline config in an environment variable
+	SourceDotenv  ValueSourceKind = "dotenv"  // Value from a dotenv file
+	SourceEnv     ValueSourceKind = "env"     // Value from an environment variable
+	SourceFlag    ValueSourceKind = "flag"    // Value from a command line flag
+	SourceSet     ValueSourceKind = "set"     // Value from --set or --set-file override
+	SourceCustom  ValueSourceKind = "custom"  // Value from a custom source of ConfigurePlan.CustomSources
+)
+
+// ValueSource describes where an effective config value came from
+type ValueSource struct {
+	Kind ValueSourceKind // Kind of the source
+	Name string          // File path, environment variable name, flag name, override expression or custom source name. Empty for defaults
+}
+
+func (source ValueSource) String() string {
+	if source.Name == "" {
+		return string(source.Kind)
+	}
+	return string(source.Kind) + ":" + source.Name
+}
+
+// envKeyReplacer converts config keys into environment variable names. Dots inside map keys are replaced too
+func envKeyReplacer(delimiter string) *strings.Replacer {
+	return strings.NewReplacer(delimiter, "_", ".", "_")
+}
+
+// envVariableName returns environment variable name that viper looks up for a config key
+func envVariableName(configPlan *ConfigurePlan, key string) string {
+	name := strings.ToUpper(envKeyReplacer(configPlan.ConfigParsingRules.keyDelimiter()).Replace(key))
+	if configPlan.EnvVariablesPrefix != "" {
+		name = strings.ToUpper(configPlan.EnvVariablesPrefix) + "_" + name
+	}
+	return name
+}
+
+// bindStructEnv binds set environment variables of cfgStruct keys.
+// AutomaticEnv only covers keys that are already known to viper from defaults or config files
+func bindStructEnv(vp *viper.Viper, configPlan *ConfigurePlan, cfgStruct interface{}) {
+	walkConfigStruct(cfgStruct, configPlan.ConfigParsingRules.keyDelimiter(), func(field configStructField) bool {
+		envName := envVariableName(configPlan, field.Key)
+		if _, ok := os.LookupEnv(envName); ok {
+			_ = vp.BindEnv(field.Key, envName)
+		}
+		return true
+	})
+}
+
+// buildProvenance finds a source of every effective config key following viper precedence (override > flag > env > file > default)
+func buildProvenance(result *ConfigurationResult, bindFlags map[string]*pflag.Flag) map[string]ValueSource {
+	configPlan := result.ConfigurePlan
+	delimiter := configPlan.ConfigParsingRules.keyDelimiter()
+	subtreePrefix := ""
+	if configPlan.ConfigParsingRules.ExtractSubtree != "" {
+		subtreePrefix = configPlan.ConfigParsingRules.ExtractSubtree + delimiter
+	}
+
+	provenance := map[string]ValueSource{}
+	for _, key := range result.Viper.AllKeys() {
+		if source, ok := overrideSource(result.overrides, key, delimiter); ok {
+			provenance[key] = source
+			continue
+		}
+		if flag, ok := bindFlags[key]; ok && flag.Changed && !configPlan.DontBindFlagsToConfig {
+			provenance[key] = ValueSource{Kind: SourceFlag, Name: flag.Name}
+			continue
+		}
+		if !configPlan.DontBindEnvToConfig {
+			envName := envVariableName(configPlan, key)
+			if _, ok := os.LookupEnv(envName); ok {
+				provenance[key] = ValueSource{Kind: SourceEnv, Name: envName}
+				continue
+			}
+		}
+		provenance[key] = ValueSource{Kind: SourceDefault}
+		for idx := len(result.configFiles) - 1; idx >= 0; idx-- {
+			if _, ok := lookupKey(result.configFiles[idx].Settings, subtreePrefix+key, delimiter); ok {
+				provenance[key] = ValueSource{Kind: result.configFiles[idx].Kind, Name: result.configFiles[idx].Path}
+				break
+			}
+		}
+	}
+	return provenance
+}
+
+// overrideSource finds an override of key or any of its parent keys
+func overrideSource(overrides map[string]ValueSource, key string, delimiter string) (ValueSource, bool) {
+	for {
+		if source, ok := overrides[key]; ok {
+			return source, true
+		}
+		var ok bool
+		if key, ok = parentKey(key, delimiter); !ok {
+			return ValueSource{}, false
+		}
+	}
+}
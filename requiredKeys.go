@@ -0,0 +1,67 @@
+package xcommon
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// collectRequiredKeys returns sorted lowercased required keys from configPlan and from `required:"true"` tags of cfgStruct
+func collectRequiredKeys(configPlan *ConfigurePlan, cfgStruct interface{}) []string {
+	keys := map[string]bool{}
+	for _, key := range configPlan.RequiredKeys {
+		keys[strings.ToLower(key)] = true
+	}
+	walkConfigStruct(cfgStruct, configPlan.ConfigParsingRules.keyDelimiter(), func(field configStructField) bool {
+		if field.Field.Tag.Get("required") == "true" {
+			keys[field.Key] = true
+		}
+		return true
+	})
+	ret := make([]string, 0, len(keys))
+	for key := range keys {
+		ret = append(ret, key)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// checkRequiredKeys checks that all required keys of configPlan and cfgStruct are set by any source after all layers are merged.
+// The error lists every way a missing key could be provided
+func checkRequiredKeys(result *ConfigurationResult, bindFlags map[string]*pflag.Flag, cfgStruct interface{}) error {
+	configPlan := result.ConfigurePlan
+	var missing []string
+	for _, key := range collectRequiredKeys(configPlan, cfgStruct) {
+		if value := result.Viper.Get(key); result.Viper.IsSet(key) && value != nil && value != "" {
+			continue
+		}
+		sources := requiredKeySources(configPlan, bindFlags, key)
+		described := sources[0]
+		if len(sources) > 1 {
+			described = strings.Join(sources[:len(sources)-1], ", ") + " or " + sources[len(sources)-1]
+		}
+		missing = append(missing, fmt.Sprintf("%s: provide it with %s", key, described))
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return fmt.Errorf("required config keys are not set:\n  %s", strings.Join(missing, "\n  "))
+}
+
+// requiredKeySources describes all ways to provide a config key
+func requiredKeySources(configPlan *ConfigurePlan, bindFlags map[string]*pflag.Flag, key string) []string {
+	fileKey := joinKey(configPlan.ConfigParsingRules.ExtractSubtree, key, configPlan.ConfigParsingRules.keyDelimiter())
+	sources := []string{fmt.Sprintf("config file key '%s'", fileKey)}
+	if !configPlan.DontBindEnvToConfig {
+		sources = append(sources, "environment variable "+envVariableName(configPlan, key))
+	}
+	if flag, ok := bindFlags[key]; ok && !configPlan.DontBindFlagsToConfig {
+		sources = append(sources, "flag --"+flag.Name)
+	}
+	if configPlan.SetFlag != "" {
+		sources = append(sources, fmt.Sprintf("--%s %s=<value>", configPlan.SetFlag, key))
+	}
+	return sources
+}
@@ -0,0 +1,107 @@
+package xcommon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	redactedValue    = "<redacted>"
+	secretFilePrefix = "file:" // Prefix of secret values that reference a file with the secret
+)
+
+// collectSecretKeys returns lowercased secret keys from configPlan and from `secret:"true"` tags of cfgStruct
+func collectSecretKeys(configPlan *ConfigurePlan, cfgStruct interface{}) map[string]bool {
+	ret := map[string]bool{}
+	for _, key := range configPlan.SecretKeys {
+		ret[strings.ToLower(key)] = true
+	}
+	walkConfigStruct(cfgStruct, configPlan.ConfigParsingRules.keyDelimiter(), func(field configStructField) bool {
+		if field.Field.Tag.Get("secret") == "true" {
+			ret[field.Key] = true
+		}
+		return true
+	})
+	return ret
+}
+
+// isSecretKey checks if key or any of its parent keys is secret
+func isSecretKey(secretKeys map[string]bool, key string, delimiter string) bool {
+	key = strings.ToLower(key)
+	for {
+		if secretKeys[key] {
+			return true
+		}
+		var ok bool
+		if key, ok = parentKey(key, delimiter); !ok {
+			return false
+		}
+	}
+}
+
+// redactSecrets returns a copy of nested settings with secret values redacted
+func redactSecrets(settings map[string]interface{}, secretKeys map[string]bool, delimiter string) map[string]interface{} {
+	flat := map[string]interface{}{}
+	flattenValues(settings, "", flat, delimiter)
+	ret := map[string]interface{}{}
+	for key, value := range flat {
+		if isSecretKey(secretKeys, key, delimiter) {
+			value = redactedValue
+		}
+		setKey(ret, key, value, delimiter)
+	}
+	return ret
+}
+
+// redactedSettings returns effective settings as a nested map with secret values redacted
+func (result *ConfigurationResult) redactedSettings() map[string]interface{} {
+	secretKeys := collectSecretKeys(result.ConfigurePlan, result.cfgStruct)
+	delimiter := result.ConfigurePlan.ConfigParsingRules.keyDelimiter()
+	settings := map[string]interface{}{}
+	for _, key := range result.Viper.AllKeys() {
+		value := result.Viper.Get(key)
+		if isSecretKey(secretKeys, key, delimiter) {
+			value = redactedValue
+		}
+		setKey(settings, key, value, delimiter)
+	}
+	return settings
+}
+
+// secretFileReference returns path of a "file:path" secret reference
+func secretFileReference(value interface{}) (string, bool) {
+	str, ok := value.(string)
+	if !ok || !strings.HasPrefix(str, secretFilePrefix) || len(str) == len(secretFilePrefix) {
+		return "", false
+	}
+	return str[len(secretFilePrefix):], true
+}
+
+// secretFileReferencesHook returns config file hook that replaces "file:path" values of secret keys with contents of the files.
+// Relative paths are relative to the config file. Permissions of referenced files are checked with policy
+func secretFileReferencesHook(policy FilePermissionPolicy, secretKeys map[string]bool, delimiter string) configFileHook {
+	return func(file *configFile) error {
+		flat := map[string]interface{}{}
+		flattenValues(file.Settings, "", flat, delimiter)
+		for _, key := range sortedKeys(flat) {
+			path, ok := secretFileReference(flat[key])
+			if !ok || !isSecretKey(secretKeys, key, delimiter) {
+				continue
+			}
+			if path = expandPath(path); !filepath.IsAbs(path) && file.Kind == SourceFile && file.Path != stdinConfigPath {
+				path = filepath.Join(filepath.Dir(file.Path), path)
+			}
+			if err := checkSecretFile(policy, path, "secret file"); err != nil {
+				return err
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("config file '%s', key '%s': unable to read secret file: %w", file.Path, key, err)
+			}
+			setKey(file.Settings, key, strings.TrimRight(string(data), "\r\n"), delimiter)
+		}
+		return nil
+	}
+}
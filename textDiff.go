@@ -0,0 +1,116 @@
+package xcommon
+
+import (
+	"fmt"
+	"strings"
+)
+
+const diffContextLines = 3
+
+type diffLine struct {
+	Op   byte // ' ', '-' or '+'
+	Text string
+}
+
+// unifiedDiff returns a line-based unified diff between a and b or empty string if they are equal
+func unifiedDiff(aName string, bName string, a string, b string) string {
+	if a == b {
+		return ""
+	}
+	lines := diffLines(splitLines(a), splitLines(b))
+
+	out := &strings.Builder{}
+	fmt.Fprintf(out, "--- %s\n+++ %s\n", aName, bName)
+	for start := 0; start < len(lines); {
+		// Find next change
+		for start < len(lines) && lines[start].Op == ' ' {
+			start++
+		}
+		if start == len(lines) {
+			break
+		}
+		hunkStart := max(start-diffContextLines, 0)
+		hunkEnd := start
+		for idx := start; idx < len(lines); idx++ {
+			if lines[idx].Op != ' ' {
+				hunkEnd = idx + 1
+			} else if idx-hunkEnd >= 2*diffContextLines {
+				break
+			}
+		}
+		hunkEnd = min(hunkEnd+diffContextLines, len(lines))
+
+		aStart, bStart := 1, 1
+		for _, line := range lines[:hunkStart] {
+			if line.Op != '+' {
+				aStart++
+			}
+			if line.Op != '-' {
+				bStart++
+			}
+		}
+		aCount, bCount := 0, 0
+		for _, line := range lines[hunkStart:hunkEnd] {
+			if line.Op != '+' {
+				aCount++
+			}
+			if line.Op != '-' {
+				bCount++
+			}
+		}
+		fmt.Fprintf(out, "@@ -%d,%d +%d,%d @@\n", aStart, aCount, bStart, bCount)
+		for _, line := range lines[hunkStart:hunkEnd] {
+			fmt.Fprintf(out, "%c%s\n", line.Op, line.Text)
+		}
+		start = hunkEnd
+	}
+	return out.String()
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffLines builds an edit script between a and b using longest common subsequence
+func diffLines(a []string, b []string) []diffLine {
+	lcs := make([][]int, len(a)+1)
+	for idx := range lcs {
+		lcs[idx] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ret []diffLine
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ret = append(ret, diffLine{Op: ' ', Text: a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ret = append(ret, diffLine{Op: '-', Text: a[i]})
+			i++
+		default:
+			ret = append(ret, diffLine{Op: '+', Text: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ret = append(ret, diffLine{Op: '-', Text: a[i]})
+	}
+	for ; j < len(b); j++ {
+		ret = append(ret, diffLine{Op: '+', Text: b[j]})
+	}
+	return ret
+}
@@ -0,0 +1,279 @@
+package xcommon
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// byteSizeUnits maps lowercased size suffixes to multipliers. Decimal (KB) and binary (KiB) units are supported
+var byteSizeUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"k":   1e3,
+	"kb":  1e3,
+	"ki":  1 << 10,
+	"kib": 1 << 10,
+	"m":   1e6,
+	"mb":  1e6,
+	"mi":  1 << 20,
+	"mib": 1 << 20,
+	"g":   1e9,
+	"gb":  1e9,
+	"gi":  1 << 30,
+	"gib": 1 << 30,
+	"t":   1e12,
+	"tb":  1e12,
+	"ti":  1 << 40,
+	"tib": 1 << 40,
+	"p":   1e15,
+	"pb":  1e15,
+	"pi":  1 << 50,
+	"pib": 1 << 50,
+}
+
+// parseByteSize parses sizes like "512MiB", "10MB", "1.5G" or "1024" into number of bytes
+func parseByteSize(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	numberEnd := strings.IndexFunc(value, func(r rune) bool {
+		return !(r >= '0' && r <= '9' || r == '.' || r == '-' || r == '+')
+	})
+	if numberEnd < 0 {
+		numberEnd = len(value)
+	}
+	number, err := strconv.ParseFloat(value[:numberEnd], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size '%s'", value)
+	}
+	multiplier, ok := byteSizeUnits[strings.ToLower(strings.TrimSpace(value[numberEnd:]))]
+	if !ok {
+		return 0, fmt.Errorf("invalid size unit in '%s' (should be one of B, KB, KiB, MB, MiB, GB, GiB, TB, TiB, PB, PiB)", value)
+	}
+	size := number * multiplier
+	if size > math.MaxInt64 || size < math.MinInt64 {
+		return 0, fmt.Errorf("size '%s' is out of range", value)
+	}
+	return int64(size), nil
+}
+
+// ByteSize is a number of bytes that can be written in config as "512MiB", "10MB" or "1024".
+// It can be used as a config field, a default value and a pflag.Value
+type ByteSize int64
+
+// byteSizePrintUnits are units used to print ByteSize from the largest to the smallest
+var byteSizePrintUnits = []struct {
+	Name string
+	Size int64
+}{
+	{"PiB", 1 << 50}, {"PB", 1e15},
+	{"TiB", 1 << 40}, {"TB", 1e12},
+	{"GiB", 1 << 30}, {"GB", 1e9},
+	{"MiB", 1 << 20}, {"MB", 1e6},
+	{"KiB", 1 << 10}, {"KB", 1e3},
+}
+
+// String returns the size in the largest unit that represents it exactly
+func (size ByteSize) String() string {
+	if size != 0 {
+		for _, unit := range byteSizePrintUnits {
+			if int64(size)%unit.Size == 0 {
+				return strconv.FormatInt(int64(size)/unit.Size, 10) + unit.Name
+			}
+		}
+	}
+	return strconv.FormatInt(int64(size), 10) + "B"
+}
+
+func (size ByteSize) MarshalText() ([]byte, error) {
+	return []byte(size.String()), nil
+}
+
+func (size *ByteSize) UnmarshalText(text []byte) error {
+	parsed, err := parseByteSize(string(text))
+	if err != nil {
+		return err
+	}
+	*size = ByteSize(parsed)
+	return nil
+}
+
+// Set implements pflag.Value
+func (size *ByteSize) Set(value string) error {
+	return size.UnmarshalText([]byte(value))
+}
+
+// Type implements pflag.Value
+func (size *ByteSize) Type() string {
+	return "bytesize"
+}
+
+// Duration is a time.Duration that additionally accepts days ("d") and weeks ("w"), e.g. "1d12h" or "1m30s"
+// It can be used as a config field, a default value and a pflag.Value
+type Duration time.Duration
+
+var durationDaysRe = regexp.MustCompile(`([0-9]*(?:\.[0-9]+)?)([dw])`)
+
+// parseDuration parses durations with days and weeks support
+func parseDuration(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	var convErr error
+	converted := durationDaysRe.ReplaceAllStringFunc(value, func(match string) string {
+		parts := durationDaysRe.FindStringSubmatch(match)
+		number, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			convErr = err
+			return match
+		}
+		hours := number * 24
+		if parts[2] == "w" {
+			hours *= 7
+		}
+		return strconv.FormatFloat(hours, 'f', -1, 64) + "h"
+	})
+	duration, err := time.ParseDuration(converted)
+	if err != nil || convErr != nil {
+		return 0, fmt.Errorf("invalid duration '%s' (example: 1d12h, 1m30s, 500ms)", value)
+	}
+	return duration, nil
+}
+
+// formatDuration prints a duration with days and without zero trailing units (36h -> "1d12h")
+func formatDuration(duration time.Duration) string {
+	if duration == 0 {
+		return "0s"
+	}
+	sign := ""
+	if duration < 0 {
+		sign = "-"
+		duration = -duration
+	}
+	days := duration / (24 * time.Hour)
+	rest := duration % (24 * time.Hour)
+	ret := ""
+	if days > 0 {
+		ret = strconv.FormatInt(int64(days), 10) + "d"
+	}
+	if rest > 0 {
+		restStr := rest.String()
+		if strings.HasSuffix(restStr, "m0s") {
+			restStr = strings.TrimSuffix(restStr, "0s")
+		}
+		if strings.HasSuffix(restStr, "h0m") {
+			restStr = strings.TrimSuffix(restStr, "0m")
+		}
+		ret += restStr
+	}
+	return sign + ret
+}
+
+func (duration Duration) String() string {
+	return formatDuration(time.Duration(duration))
+}
+
+// Duration returns value as time.Duration
+func (duration Duration) Duration() time.Duration {
+	return time.Duration(duration)
+}
+
+func (duration Duration) MarshalText() ([]byte, error) {
+	return []byte(duration.String()), nil
+}
+
+func (duration *Duration) UnmarshalText(text []byte) error {
+	parsed, err := parseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*duration = Duration(parsed)
+	return nil
+}
+
+// Set implements pflag.Value
+func (duration *Duration) Set(value string) error {
+	return duration.UnmarshalText([]byte(value))
+}
+
+// Type implements pflag.Value
+func (duration *Duration) Type() string {
+	return "duration"
+}
+
+// Rate is a number of events per time interval. It's written in config as "100/s", "5/min" or "1000/10m"
+// It can be used as a config field, a default value and a pflag.Value
+type Rate struct {
+	Count float64       // Number of events
+	Per   time.Duration // Time interval
+}
+
+var rateUnits = map[string]time.Duration{
+	"ms": time.Millisecond, "s": time.Second, "sec": time.Second, "second": time.Second,
+	"m": time.Minute, "min": time.Minute, "minute": time.Minute,
+	"h": time.Hour, "hour": time.Hour, "d": 24 * time.Hour, "day": 24 * time.Hour,
+}
+
+// PerSecond returns the rate as a number of events per second
+func (rate Rate) PerSecond() float64 {
+	if rate.Per <= 0 {
+		return 0
+	}
+	return rate.Count / rate.Per.Seconds()
+}
+
+// Interval returns time between two events
+func (rate Rate) Interval() time.Duration {
+	if rate.Count <= 0 {
+		return 0
+	}
+	return time.Duration(float64(rate.Per) / rate.Count)
+}
+
+func (rate Rate) String() string {
+	per := formatDuration(rate.Per)
+	for _, unit := range []string{"ms", "s", "m", "h", "d"} {
+		if rate.Per == rateUnits[unit] {
+			per = unit
+			break
+		}
+	}
+	return strconv.FormatFloat(rate.Count, 'f', -1, 64) + "/" + per
+}
+
+func (rate Rate) MarshalText() ([]byte, error) {
+	return []byte(rate.String()), nil
+}
+
+func (rate *Rate) UnmarshalText(text []byte) error {
+	value := strings.TrimSpace(string(text))
+	countStr, perStr, ok := strings.Cut(value, "/")
+	if !ok {
+		return fmt.Errorf("invalid rate '%s' (example: 100/s, 5/min, 1000/10m)", value)
+	}
+	count, err := strconv.ParseFloat(strings.TrimSpace(countStr), 64)
+	if err != nil || count < 0 {
+		return fmt.Errorf("invalid rate count in '%s'", value)
+	}
+	perStr = strings.TrimSpace(perStr)
+	per, ok := rateUnits[strings.ToLower(perStr)]
+	if !ok {
+		per, err = parseDuration(perStr)
+		if err != nil || per <= 0 {
+			return fmt.Errorf("invalid rate interval in '%s' (example: 100/s, 5/min, 1000/10m)", value)
+		}
+	}
+	rate.Count = count
+	rate.Per = per
+	return nil
+}
+
+// Set implements pflag.Value
+func (rate *Rate) Set(value string) error {
+	return rate.UnmarshalText([]byte(value))
+}
+
+// Type implements pflag.Value
+func (rate *Rate) Type() string {
+	return "rate"
+}
@@ -0,0 +1,204 @@
+package xcommon
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"1024", 1024},
+		{"0", 0},
+		{"512MiB", 512 << 20},
+		{"10MB", 10e6},
+		{"1.5G", 1.5e9},
+		{"1KiB", 1024},
+		{"1KB", 1000},
+		{"1k", 1000},
+		{"2 kib", 2048},
+		{"3b", 3},
+		{"1TiB", 1 << 40},
+	}
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			got, err := parseByteSize(test.in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("got %d, want %d", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseByteSizeErrors(t *testing.T) {
+	for _, in := range []string{"", "MiB", "10XB", "1.2.3KB", "10000000PB"} {
+		t.Run(in, func(t *testing.T) {
+			if _, err := parseByteSize(in); err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
+
+func TestByteSizeRoundTrip(t *testing.T) {
+	tests := []struct {
+		size ByteSize
+		want string
+	}{
+		{0, "0B"},
+		{1, "1B"},
+		{1000, "1KB"},
+		{1024, "1KiB"},
+		{1536, "1536B"},
+		{1024000, "1000KiB"},
+		{1024e6, "1024MB"},
+		{2e6, "2MB"},
+		{3 << 30, "3GiB"},
+		{-2048, "-2KiB"},
+	}
+	for _, test := range tests {
+		t.Run(test.want, func(t *testing.T) {
+			text, err := test.size.MarshalText()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(text) != test.want {
+				t.Errorf("got %s, want %s", text, test.want)
+			}
+			var parsed ByteSize
+			if err := parsed.UnmarshalText(text); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if parsed != test.size {
+				t.Errorf("round trip got %d, want %d", parsed, test.size)
+			}
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"1d12h", 36 * time.Hour},
+		{"1w", 7 * 24 * time.Hour},
+		{"0.5d", 12 * time.Hour},
+		{"1m30s", 90 * time.Second},
+		{"500ms", 500 * time.Millisecond},
+		{"2d30m", 48*time.Hour + 30*time.Minute},
+		{"-1d", -24 * time.Hour},
+	}
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			got, err := parseDuration(test.in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseDurationErrors(t *testing.T) {
+	for _, in := range []string{"", "1", "d", "1x", "1.2.3d"} {
+		t.Run(in, func(t *testing.T) {
+			if _, err := parseDuration(in); err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
+
+func TestDurationRoundTrip(t *testing.T) {
+	tests := []struct {
+		duration time.Duration
+		want     string
+	}{
+		{0, "0s"},
+		{36 * time.Hour, "1d12h"},
+		{24 * time.Hour, "1d"},
+		{2 * time.Hour, "2h"},
+		{90 * time.Minute, "1h30m"},
+		{90 * time.Second, "1m30s"},
+		{25*time.Hour + 30*time.Minute, "1d1h30m"},
+		{500 * time.Millisecond, "500ms"},
+		{-36 * time.Hour, "-1d12h"},
+	}
+	for _, test := range tests {
+		t.Run(test.want, func(t *testing.T) {
+			if got := formatDuration(test.duration); got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+			var parsed Duration
+			if err := parsed.UnmarshalText([]byte(test.want)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if parsed.Duration() != test.duration {
+				t.Errorf("round trip got %s, want %s", parsed.Duration(), test.duration)
+			}
+		})
+	}
+}
+
+func TestRateRoundTrip(t *testing.T) {
+	tests := []struct {
+		in        string
+		want      Rate
+		text      string
+		perSecond float64
+	}{
+		{"100/s", Rate{Count: 100, Per: time.Second}, "100/s", 100},
+		{"5/min", Rate{Count: 5, Per: time.Minute}, "5/m", 5.0 / 60},
+		{"1000/10m", Rate{Count: 1000, Per: 10 * time.Minute}, "1000/10m", 1000.0 / 600},
+		{"2.5 / hour", Rate{Count: 2.5, Per: time.Hour}, "2.5/h", 2.5 / 3600},
+		{"10/1d12h", Rate{Count: 10, Per: 36 * time.Hour}, "10/1d12h", 10.0 / (36 * 3600)},
+		{"1/500ms", Rate{Count: 1, Per: 500 * time.Millisecond}, "1/500ms", 2},
+	}
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			var rate Rate
+			if err := rate.UnmarshalText([]byte(test.in)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if rate != test.want {
+				t.Errorf("got %+v, want %+v", rate, test.want)
+			}
+			if got := rate.PerSecond(); got != test.perSecond {
+				t.Errorf("got %v per second, want %v", got, test.perSecond)
+			}
+			text, err := rate.MarshalText()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(text) != test.text {
+				t.Errorf("got %s, want %s", text, test.text)
+			}
+			var parsed Rate
+			if err := parsed.UnmarshalText(text); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if parsed != rate {
+				t.Errorf("round trip got %+v, want %+v", parsed, rate)
+			}
+		})
+	}
+}
+
+func TestRateErrors(t *testing.T) {
+	for _, in := range []string{"100", "x/s", "-1/s", "1/0s", "1/fortnight"} {
+		t.Run(in, func(t *testing.T) {
+			var rate Rate
+			if err := rate.UnmarshalText([]byte(in)); err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}